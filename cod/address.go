@@ -0,0 +1,332 @@
+package cod
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/seedhammer/bip-serialized-descriptors/internal/base58"
+	"github.com/seedhammer/bip-serialized-descriptors/internal/bech32"
+	"github.com/seedhammer/bip-serialized-descriptors/internal/secp256k1"
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// This file implements address derivation for the common descriptor
+// types: pkh, wpkh, sh(wpkh), tr with a single key and no script tree,
+// and multi, sortedmulti, pk and pkh scripts wrapped in sh, wsh or
+// sh(wsh).
+
+// Address returns the address at the given chain and index of the
+// descriptor. The chain selects the element of multipath groups, such
+// as 0 for receive and 1 for change in @0/<0;1>/*, and the index
+// replaces the * wildcard. The address network is determined by the
+// key version bytes, which don't distinguish the test networks, so
+// keys of regtest and signet result in testnet addresses. Use
+// NetworkAddress for regtest addresses.
+func (d OutputDescriptor) Address(chain, index uint32) (string, error) {
+	net, err := d.Network()
+	if err != nil {
+		return "", err
+	}
+	return d.NetworkAddress(net, chain, index)
+}
+
+// NetworkAddress is like Address, but returns the address for the
+// network net. Mainnet keys only result in mainnet addresses, and
+// testnet keys in addresses of the other networks.
+func (d OutputDescriptor) NetworkAddress(net psbt.Network, chain, index uint32) (string, error) {
+	tmpl, _, _ := strings.Cut(d.Descriptor, "#")
+	e, err := parseExpr(strings.ReplaceAll(tmpl, "/**", multipathSuffix))
+	if err != nil {
+		return "", fmt.Errorf("serdesc: %w", err)
+	}
+	keyNet, err := d.Network()
+	if err != nil {
+		return "", err
+	}
+	if (keyNet == psbt.Mainnet) != (net == psbt.Mainnet) {
+		return "", fmt.Errorf("serdesc: %s address of %s keys", net, keyNet)
+	}
+	a := &addresser{keys: d.Keys, chain: chain, index: index}
+	script, err := a.outputScript(e)
+	if err != nil {
+		return "", fmt.Errorf("serdesc: address: %w", err)
+	}
+	if err := a.checkUsed(); err != nil {
+		return "", fmt.Errorf("serdesc: address: %w", err)
+	}
+	addr, err := scriptAddress(script, net)
+	if err != nil {
+		return "", fmt.Errorf("serdesc: address: %w", err)
+	}
+	return addr, nil
+}
+
+// Script returns the witness script of a wsh or sh(wsh) descriptor,
+// or the redeem script of any other sh descriptor, at the given chain
+// and index as described for Address.
+func (d OutputDescriptor) Script(chain, index uint32) (psbt.Script, error) {
+	tmpl, _, _ := strings.Cut(d.Descriptor, "#")
+	e, err := parseExpr(strings.ReplaceAll(tmpl, "/**", multipathSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("serdesc: %w", err)
+	}
+	if len(e.args) == 1 && e.name == "sh" && e.args[0].name == "wsh" {
+		e = e.args[0]
+	}
+	if len(e.args) != 1 || (e.name != "wsh" && e.name != "sh") || e.args[0].name == "wpkh" {
+		return nil, fmt.Errorf("serdesc: script: descriptor %s has no witness or redeem script", e.name)
+	}
+	a := &addresser{keys: d.Keys, chain: chain, index: index}
+	script, err := a.script(e.args[0])
+	if err != nil {
+		return nil, fmt.Errorf("serdesc: script: %w", err)
+	}
+	if err := a.checkUsed(); err != nil {
+		return nil, fmt.Errorf("serdesc: script: %w", err)
+	}
+	return script, nil
+}
+
+// addresser derives addresses from script expressions.
+type addresser struct {
+	keys  []psbt.ExtendedKey
+	chain uint32
+	index uint32
+	// multipath and wildcard record whether any key
+	// consumed the chain or index.
+	multipath bool
+	wildcard  bool
+}
+
+// checkUsed checks that a non-zero chain or index was consumed
+// by a multipath group or wildcard.
+func (a *addresser) checkUsed() error {
+	if a.chain != 0 && !a.multipath {
+		return fmt.Errorf("chain %d of descriptor without multipath groups", a.chain)
+	}
+	if a.index != 0 && !a.wildcard {
+		return fmt.Errorf("index %d of descriptor without wildcard", a.index)
+	}
+	return nil
+}
+
+// outputScript returns the output script of a descriptor expression.
+func (a *addresser) outputScript(e expr) (psbt.Script, error) {
+	if len(e.args) != 1 {
+		return nil, fmt.Errorf("unsupported descriptor %s", e.name)
+	}
+	arg := e.args[0]
+	switch {
+	case e.name == "pkh" && arg.name == "":
+		pk, err := a.pubKey(arg.text)
+		if err != nil {
+			return nil, err
+		}
+		return psbt.P2PKHScript(pk), nil
+	case e.name == "wpkh" && arg.name == "":
+		pk, err := a.pubKey(arg.text)
+		if err != nil {
+			return nil, err
+		}
+		return psbt.P2WPKHScript(pk), nil
+	case e.name == "wsh":
+		script, err := a.script(arg)
+		if err != nil {
+			return nil, err
+		}
+		return script.P2WSH(), nil
+	case e.name == "sh":
+		var redeem psbt.Script
+		switch {
+		case arg.name == "wpkh" && len(arg.args) == 1 && arg.args[0].name == "":
+			pk, err := a.pubKey(arg.args[0].text)
+			if err != nil {
+				return nil, err
+			}
+			redeem = psbt.P2WPKHScript(pk)
+		case arg.name == "wsh" && len(arg.args) == 1:
+			script, err := a.script(arg.args[0])
+			if err != nil {
+				return nil, err
+			}
+			redeem = script.P2WSH()
+		default:
+			script, err := a.script(arg)
+			if err != nil {
+				return nil, err
+			}
+			redeem = script
+		}
+		return redeem.P2SH(), nil
+	case e.name == "tr" && arg.name == "":
+		pk, err := a.pubKey(arg.text)
+		if err != nil {
+			return nil, err
+		}
+		q, err := taprootOutputKey(pk)
+		if err != nil {
+			return nil, err
+		}
+		return psbt.P2TRScript(q), nil
+	default:
+		return nil, fmt.Errorf("unsupported descriptor %s", e.name)
+	}
+}
+
+// script returns the script of a multi, sortedmulti, pk or
+// pkh expression.
+func (a *addresser) script(e expr) (psbt.Script, error) {
+	switch e.name {
+	case "pk", "pkh":
+		if len(e.args) != 1 || e.args[0].name != "" {
+			return nil, fmt.Errorf("%s: expected a key argument", e.name)
+		}
+		pk, err := a.pubKey(e.args[0].text)
+		if err != nil {
+			return nil, err
+		}
+		if e.name == "pk" {
+			return psbt.PKScript(pk), nil
+		}
+		return psbt.P2PKHScript(pk), nil
+	case "multi", "sortedmulti":
+		if len(e.args) < 2 {
+			return nil, fmt.Errorf("%s: missing keys", e.name)
+		}
+		k, err := strconv.Atoi(e.args[0].text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid threshold %q", e.name, e.args[0].text)
+		}
+		var pks [][]byte
+		for _, arg := range e.args[1:] {
+			if arg.name != "" {
+				return nil, fmt.Errorf("%s: unexpected %s", e.name, arg.name)
+			}
+			pk, err := a.pubKey(arg.text)
+			if err != nil {
+				return nil, err
+			}
+			pks = append(pks, pk)
+		}
+		if e.name == "sortedmulti" {
+			return psbt.SortedMultiScript(k, pks)
+		}
+		return psbt.MultiScript(k, pks)
+	case "":
+		return nil, fmt.Errorf("unexpected argument %q", e.text)
+	default:
+		return nil, fmt.Errorf("unsupported script %s", e.name)
+	}
+}
+
+// pubKey derives the public key of a key argument.
+func (a *addresser) pubKey(arg string) ([]byte, error) {
+	idx, suffix, err := parseKeyArg(arg)
+	if err != nil {
+		return nil, err
+	}
+	if idx >= len(a.keys) {
+		return nil, fmt.Errorf("key placeholder @%d out of range", idx)
+	}
+	k := a.keys[idx]
+	if len(k.PubKey()) == 0 {
+		return nil, fmt.Errorf("key @%d: invalid extended key", idx)
+	}
+	if suffix == "" {
+		suffix = "/"
+	}
+	for _, s := range strings.Split(suffix[1:], "/") {
+		if s == "" {
+			continue
+		}
+		var child uint32
+		switch {
+		case strings.HasSuffix(s, "'") || strings.HasSuffix(s, "h"):
+			return nil, fmt.Errorf("key @%d: hardened derivation %q from public key", idx, s)
+		case s == "*":
+			child = a.index
+			a.wildcard = true
+		case strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">"):
+			elems := strings.Split(s[1:len(s)-1], ";")
+			if int64(a.chain) >= int64(len(elems)) {
+				return nil, fmt.Errorf("key @%d: chain %d out of range for %s", idx, a.chain, s)
+			}
+			s = elems[a.chain]
+			a.multipath = true
+			fallthrough
+		default:
+			if strings.TrimLeft(s, "0123456789") != "" {
+				return nil, fmt.Errorf("key @%d: invalid derivation %q", idx, s)
+			}
+			n, err := strconv.ParseUint(s, 10, 31)
+			if err != nil {
+				return nil, fmt.Errorf("key @%d: invalid derivation %q", idx, s)
+			}
+			child = uint32(n)
+		}
+		k, err = k.Derive(child)
+		if err != nil {
+			return nil, fmt.Errorf("key @%d: %w", idx, err)
+		}
+	}
+	return k.PubKey(), nil
+}
+
+// scriptAddress returns the address of a P2PKH, P2SH or witness
+// output script.
+func scriptAddress(s psbt.Script, net psbt.Network) (string, error) {
+	var version byte
+	switch {
+	case len(s) == 25 && s[0] == psbt.OP_DUP && s[1] == psbt.OP_HASH160 && s[2] == 20 &&
+		s[23] == psbt.OP_EQUALVERIFY && s[24] == psbt.OP_CHECKSIG:
+		version = 0x00
+		if net != psbt.Mainnet {
+			version = 0x6f
+		}
+		return base58.CheckEncode(append([]byte{version}, s[3:23]...)), nil
+	case len(s) == 23 && s[0] == psbt.OP_HASH160 && s[1] == 20 && s[22] == psbt.OP_EQUAL:
+		version = 0x05
+		if net != psbt.Mainnet {
+			version = 0xc4
+		}
+		return base58.CheckEncode(append([]byte{version}, s[2:22]...)), nil
+	}
+	v, program, err := s.WitnessProgram()
+	if err != nil {
+		return "", err
+	}
+	var hrp string
+	switch net {
+	case psbt.Mainnet:
+		hrp = "bc"
+	case psbt.Testnet, psbt.Signet:
+		hrp = "tb"
+	case psbt.Regtest:
+		hrp = "bcrt"
+	default:
+		return "", fmt.Errorf("unknown network %s", net)
+	}
+	return bech32.SegwitAddress(hrp, v, program)
+}
+
+// taprootOutputKey returns the BIP-86 output key of an internal
+// key without script tree.
+func taprootOutputKey(pubkey []byte) ([]byte, error) {
+	p, err := secp256k1.LiftX(pubkey[1:])
+	if err != nil {
+		return nil, err
+	}
+	t := secp256k1.TaggedHash("TapTweak", p.XOnly())
+	tweak := new(big.Int).SetBytes(t[:])
+	if tweak.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("invalid taproot tweak")
+	}
+	q := secp256k1.Add(p, secp256k1.ScalarBaseMult(tweak))
+	if q.IsInfinity() {
+		return nil, errors.New("invalid taproot tweak")
+	}
+	return q.XOnly(), nil
+}
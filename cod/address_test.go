@@ -0,0 +1,51 @@
+package cod
+
+import (
+	"encoding/binary"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+func TestNetworkAddress(t *testing.T) {
+	k := testKey(t)
+	// Change the xpub to a tpub.
+	k.Key = slices.Clone(k.Key)
+	binary.BigEndian.PutUint32(k.Key, 0x043587cf)
+	d := OutputDescriptor{Descriptor: "wpkh(@0/<0;1>/*)", Keys: []psbt.ExtendedKey{k}}
+	testnet, err := d.Address(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(testnet, "tb1q") {
+		t.Errorf("testnet address %s", testnet)
+	}
+	tests := []struct {
+		net    psbt.Network
+		prefix string
+	}{
+		{psbt.Testnet, "tb1q"},
+		{psbt.Signet, "tb1q"},
+		{psbt.Regtest, "bcrt1q"},
+	}
+	for _, test := range tests {
+		addr, err := d.NetworkAddress(test.net, 0, 0)
+		if err != nil {
+			t.Errorf("%s: %v", test.net, err)
+			continue
+		}
+		// The networks share the witness program and differ in the
+		// human readable part and checksum.
+		if !strings.HasPrefix(addr, test.prefix) || addr[len(test.prefix):len(addr)-6] != testnet[len("tb1q"):len(testnet)-6] {
+			t.Errorf("%s address %s, testnet address %s", test.net, addr, testnet)
+		}
+	}
+	if addr, err := d.NetworkAddress(psbt.Mainnet, 0, 0); err == nil {
+		t.Errorf("mainnet address %s of testnet key", addr)
+	}
+	if addr, err := (OutputDescriptor{Descriptor: d.Descriptor, Keys: []psbt.ExtendedKey{testKey(t)}}).NetworkAddress(psbt.Regtest, 0, 0); err == nil {
+		t.Errorf("regtest address %s of mainnet key", addr)
+	}
+}
@@ -0,0 +1,99 @@
+package cod
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/seedhammer/bip-serialized-descriptors/internal/base58"
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// This file implements import of BIP-129 (BSMS) descriptor records.
+
+// bsmsVersion is the first line of a descriptor record.
+const bsmsVersion = "BSMS 1.0"
+
+// ParseBSMS parses an unencrypted BIP-129 descriptor record: the
+// version line, the descriptor, the path restrictions and the first
+// address. The keys of the descriptor are replaced by placeholders,
+// and the first address must match the address at index 0 of the
+// receive chain. The path restrictions line is required but not
+// interpreted.
+func ParseBSMS(r io.Reader) (OutputDescriptor, error) {
+	var lines []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if l := strings.TrimSpace(s.Text()); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: bsms: %w", err)
+	}
+	if len(lines) != 4 {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: bsms: expected 4 lines, got %d", len(lines))
+	}
+	if lines[0] != bsmsVersion {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: bsms: unsupported version %q", lines[0])
+	}
+	desc := lines[1]
+	if err := VerifyChecksum(desc); err != nil {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: bsms: %w", err)
+	}
+	desc, _, _ = strings.Cut(desc, "#")
+	d, err := templateFromKeys(desc)
+	if err != nil {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: bsms: %w", err)
+	}
+	addr, err := d.Address(0, 0)
+	if err != nil {
+		return OutputDescriptor{}, err
+	}
+	if addr != lines[3] {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: bsms: first address %s doesn't match derived address %s", lines[3], addr)
+	}
+	return d, nil
+}
+
+// templateFromKeys replaces every [origin]xpub key of desc with a
+// placeholder and returns the resulting descriptor. Repeated keys
+// share a placeholder.
+func templateFromKeys(desc string) (OutputDescriptor, error) {
+	var d OutputDescriptor
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(desc, '[')
+		if start == -1 {
+			break
+		}
+		b.WriteString(desc[:start])
+		desc = desc[start:]
+		end := strings.IndexByte(desc, ']')
+		if end == -1 {
+			return OutputDescriptor{}, errors.New("missing ']' in key origin")
+		}
+		end++
+		for end < len(desc) && strings.IndexByte(base58.Alphabet, desc[end]) != -1 {
+			end++
+		}
+		k, err := psbt.ParseExtendedKey(desc[:end])
+		if err != nil {
+			return OutputDescriptor{}, err
+		}
+		idx := slices.IndexFunc(d.Keys, k.Equal)
+		if idx == -1 {
+			idx = len(d.Keys)
+			d.Keys = append(d.Keys, k)
+		}
+		b.WriteString("@" + strconv.Itoa(idx))
+		desc = desc[end:]
+	}
+	b.WriteString(desc)
+	d.Descriptor = b.String()
+	return d, nil
+}
@@ -0,0 +1,152 @@
+package cod
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// This file implements constructors for the common descriptor types.
+
+// Key derivation suffixes of the constructed descriptors.
+const (
+	// receiveSuffix derives the receive addresses.
+	receiveSuffix = "/0/*"
+	// multipathSuffix derives both receive and change addresses.
+	multipathSuffix = "/<0;1>/*"
+)
+
+// singleSigFunctions maps script types to the single key descriptor
+// template, where %s is the key expression.
+var singleSigFunctions = map[psbt.ScriptType]string{
+	psbt.P2PKH:      "pkh(%s)",
+	psbt.P2SHP2WPKH: "sh(wpkh(%s))",
+	psbt.P2WPKH:     "wpkh(%s)",
+	psbt.P2TR:       "tr(%s)",
+}
+
+// singleSigPurposes maps script types to the purpose of their
+// default derivation in BIP-44, BIP-49, BIP-84 and BIP-86.
+var singleSigPurposes = map[psbt.ScriptType]uint32{
+	psbt.P2PKH:      44,
+	psbt.P2SHP2WPKH: 49,
+	psbt.P2WPKH:     84,
+	psbt.P2TR:       86,
+}
+
+// SingleSigPath returns the default derivation path of the single key
+// account of the given script type and network, such as 84'/0'/0' for
+// the first P2WPKH account on mainnet. The test networks share coin
+// type 1.
+func SingleSigPath(scriptType psbt.ScriptType, net psbt.Network, account uint32) ([]uint32, error) {
+	purpose, ok := singleSigPurposes[scriptType]
+	if !ok {
+		return nil, fmt.Errorf("serdesc: unsupported single key script type %s", scriptType)
+	}
+	if account >= psbt.HardenedKeyStart {
+		return nil, fmt.Errorf("serdesc: account %d out of range", account)
+	}
+	coin := uint32(1)
+	if net == psbt.Mainnet {
+		coin = 0
+	}
+	return []uint32{
+		purpose + psbt.HardenedKeyStart,
+		coin + psbt.HardenedKeyStart,
+		account + psbt.HardenedKeyStart,
+	}, nil
+}
+
+// NewSingleSig returns the single key descriptor of the given script
+// type, such as wpkh(@0/<0;1>/*) for P2WPKH. If multipath is set the
+// key derives both the receive and change chains, otherwise only the
+// receive chain. The key must be an account key at the default
+// derivation path of the script type and its network, as returned
+// by SingleSigPath.
+func NewSingleSig(scriptType psbt.ScriptType, key psbt.ExtendedKey, multipath bool) (OutputDescriptor, error) {
+	tmpl, ok := singleSigFunctions[scriptType]
+	if !ok {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: unsupported single key script type %s", scriptType)
+	}
+	if len(key.PubKey()) == 0 {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: key @0: invalid extended key of %d bytes", len(key.Key))
+	}
+	net, err := key.Network()
+	if err != nil {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: key @0: %w", err)
+	}
+	var account uint32
+	if len(key.Path) == 3 {
+		account = key.Path[2] - psbt.HardenedKeyStart
+	}
+	want, err := SingleSigPath(scriptType, net, account)
+	if err != nil || !slices.Equal(key.Path, want) {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: key @0: derivation %s is not a %s account of %s",
+			psbt.FormatPath(key.Path), scriptType, net)
+	}
+	suffix := receiveSuffix
+	if multipath {
+		suffix = multipathSuffix
+	}
+	return OutputDescriptor{
+		Descriptor: fmt.Sprintf(tmpl, "@0"+suffix),
+		Keys:       []psbt.ExtendedKey{key},
+	}, nil
+}
+
+// multisigFunctions maps script types to the multisig descriptor
+// template, where %s is the multi or sortedmulti expression.
+var multisigFunctions = map[psbt.ScriptType]string{
+	psbt.P2SH:      "sh(%s)",
+	psbt.P2SHP2WSH: "sh(wsh(%s))",
+	psbt.P2WSH:     "wsh(%s)",
+}
+
+// maxMultisigKeys is the maximum number of multisig keys by script
+// type, limited by the 520 byte P2SH redeem script and the standard
+// limit of 20 keys for witness scripts.
+var maxMultisigKeys = map[psbt.ScriptType]int{
+	psbt.P2SH:      15,
+	psbt.P2SHP2WSH: 20,
+	psbt.P2WSH:     20,
+}
+
+// NewMultisig returns the m-of-n multisig descriptor of the given
+// script type, such as wsh(sortedmulti(2,@0/<0;1>/*,@1/<0;1>/*)) for
+// P2WSH. The keys are referenced in order and derive both the receive
+// and change chains. If sorted is set, sortedmulti is used instead
+// of multi.
+func NewMultisig(scriptType psbt.ScriptType, m int, keys []psbt.ExtendedKey, sorted bool) (OutputDescriptor, error) {
+	tmpl, ok := multisigFunctions[scriptType]
+	if !ok {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: unsupported multisig script type %s", scriptType)
+	}
+	n := len(keys)
+	if n == 0 || n > maxMultisigKeys[scriptType] {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: invalid multisig key count %d for %s", n, scriptType)
+	}
+	if m < 1 || m > n {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: multisig threshold %d out of range for %d keys", m, n)
+	}
+	var b strings.Builder
+	if sorted {
+		b.WriteString("sortedmulti(")
+	} else {
+		b.WriteString("multi(")
+	}
+	b.WriteString(strconv.Itoa(m))
+	for i, k := range keys {
+		if len(k.PubKey()) == 0 {
+			return OutputDescriptor{}, fmt.Errorf("serdesc: key @%d: invalid extended key of %d bytes", i, len(k.Key))
+		}
+		fmt.Fprintf(&b, ",@%d%s", i, multipathSuffix)
+	}
+	b.WriteByte(')')
+	return OutputDescriptor{
+		Descriptor: fmt.Sprintf(tmpl, b.String()),
+		Keys:       slices.Clone(keys),
+	}, nil
+}
@@ -0,0 +1,109 @@
+package cod
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+func TestNewSingleSig(t *testing.T) {
+	h := uint32(HardenedKeyStart)
+	tests := []struct {
+		scriptType psbt.ScriptType
+		testnet    bool
+		path       []uint32
+		want       string
+	}{
+		{psbt.P2PKH, false, []uint32{44 + h, h, h}, "pkh(@0/<0;1>/*)"},
+		{psbt.P2SHP2WPKH, false, []uint32{49 + h, h, 1 + h}, "sh(wpkh(@0/<0;1>/*))"},
+		{psbt.P2WPKH, false, []uint32{84 + h, h, h}, "wpkh(@0/<0;1>/*)"},
+		{psbt.P2TR, false, []uint32{86 + h, h, h}, "tr(@0/<0;1>/*)"},
+		{psbt.P2WPKH, true, []uint32{84 + h, 1 + h, h}, "wpkh(@0/<0;1>/*)"},
+	}
+	for _, test := range tests {
+		k := testKey(t)
+		if test.testnet {
+			k.Key = slices.Clone(k.Key)
+			binary.BigEndian.PutUint32(k.Key, 0x043587cf)
+		}
+		k.Path = test.path
+		d, err := NewSingleSig(test.scriptType, k, true)
+		if err != nil {
+			t.Errorf("%s %s: %v", test.scriptType, psbt.FormatPath(test.path), err)
+			continue
+		}
+		if d.Descriptor != test.want {
+			t.Errorf("%s: descriptor %s, want %s", test.scriptType, d.Descriptor, test.want)
+		}
+		net, err := k.Network()
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, err := SingleSigPath(test.scriptType, net, test.path[2]-h)
+		if err != nil || !slices.Equal(path, test.path) {
+			t.Errorf("SingleSigPath(%s, %s) = %s, %v, want %s", test.scriptType, net,
+				psbt.FormatPath(path), err, psbt.FormatPath(test.path))
+		}
+	}
+	mismatches := []struct {
+		scriptType psbt.ScriptType
+		path       []uint32
+	}{
+		// The purpose of another script type.
+		{psbt.P2WPKH, []uint32{49 + h, h, h}},
+		{psbt.P2TR, []uint32{84 + h, h, h}},
+		// The testnet coin type for a mainnet key.
+		{psbt.P2WPKH, []uint32{84 + h, 1 + h, h}},
+		// Unhardened account.
+		{psbt.P2WPKH, []uint32{84 + h, h, 0}},
+		// Not an account key.
+		{psbt.P2WPKH, []uint32{84 + h, h}},
+		{psbt.P2WPKH, []uint32{84 + h, h, h, 0}},
+		{psbt.P2WPKH, []uint32{48 + h, h, h, 2 + h}},
+	}
+	for _, test := range mismatches {
+		k := testKey(t)
+		k.Path = test.path
+		if _, err := NewSingleSig(test.scriptType, k, true); err == nil {
+			t.Errorf("%s with derivation %s succeeded", test.scriptType, psbt.FormatPath(test.path))
+		}
+	}
+}
+
+func TestNewMultisigKeyCount(t *testing.T) {
+	tests := []struct {
+		scriptType psbt.ScriptType
+		max        int
+	}{
+		{psbt.P2SH, 15},
+		{psbt.P2SHP2WSH, 20},
+		{psbt.P2WSH, 20},
+	}
+	k := testKey(t)
+	for _, test := range tests {
+		keys := make([]psbt.ExtendedKey, test.max+1)
+		for i := range keys {
+			keys[i] = k
+		}
+		if _, err := NewMultisig(test.scriptType, 1, keys, true); err == nil {
+			t.Errorf("%s: NewMultisig with %d keys succeeded", test.scriptType, len(keys))
+		}
+		d, err := NewMultisig(test.scriptType, test.max, keys[:test.max], true)
+		if err != nil {
+			t.Errorf("%s: NewMultisig with %d keys: %v", test.scriptType, test.max, err)
+			continue
+		}
+		// The script must parse back with every key.
+		s, err := d.Script(0, 0)
+		if err != nil {
+			t.Errorf("%s: Script: %v", test.scriptType, err)
+			continue
+		}
+		m, pubkeys, err := s.Multisig()
+		if err != nil || m != test.max || len(pubkeys) != test.max {
+			t.Errorf("%s: Multisig() = %d, %d keys, %v, want %d-of-%d", test.scriptType, m, len(pubkeys), err, test.max, test.max)
+		}
+	}
+}
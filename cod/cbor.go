@@ -0,0 +1,122 @@
+package cod
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/seedhammer/bip-serialized-descriptors/internal/cbor"
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// This file implements the output-descriptor CBOR type of BCR-2023-010,
+// the CBOR counterpart of the serialization in cod.go: the descriptor
+// template with @-prefixed key placeholders, the keys as hdkeys and an
+// optional name.
+
+// Map keys of output-descriptor.
+const (
+	cborSource = 1
+	cborKeys   = 2
+	cborName   = 3
+)
+
+// EncodeCBOR encodes desc as an untagged output-descriptor, the
+// payload of ur:output-descriptor. Unknown entries are not represented.
+func EncodeCBOR(desc OutputDescriptor) ([]byte, error) {
+	n := 1
+	if len(desc.Keys) > 0 {
+		n++
+	}
+	if desc.Name != "" {
+		n++
+	}
+	enc := new(cbor.Encoder)
+	enc.Map(n)
+	enc.Uint(cborSource)
+	enc.Text(desc.Descriptor)
+	if len(desc.Keys) > 0 {
+		enc.Uint(cborKeys)
+		enc.Array(len(desc.Keys))
+		for i, k := range desc.Keys {
+			if err := writeHDKey(enc, v2Tags, k, nil, false); err != nil {
+				return nil, fmt.Errorf("serdesc: key %d: %w", i, err)
+			}
+		}
+	}
+	if desc.Name != "" {
+		enc.Uint(cborName)
+		enc.Text(desc.Name)
+	}
+	return enc.Bytes(), nil
+}
+
+// DecodeCBOR decodes an untagged output-descriptor.
+func DecodeCBOR(data []byte) (OutputDescriptor, error) {
+	desc, err := decodeCBOR(cbor.NewDecoder(data))
+	if err != nil {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: output-descriptor: %w", err)
+	}
+	return desc, nil
+}
+
+func decodeCBOR(dec *cbor.Decoder) (OutputDescriptor, error) {
+	n, err := dec.Map()
+	if err != nil {
+		return OutputDescriptor{}, err
+	}
+	var desc OutputDescriptor
+	hasSource := false
+	for i := 0; i < n; i++ {
+		k, err := dec.Uint()
+		if err != nil {
+			return OutputDescriptor{}, err
+		}
+		switch k {
+		case cborSource:
+			desc.Descriptor, err = dec.Text()
+			hasSource = true
+		case cborName:
+			desc.Name, err = dec.Text()
+		case cborKeys:
+			err = decodeCBORKeys(dec, &desc.Keys)
+		default:
+			err = dec.Skip()
+		}
+		if err != nil {
+			return OutputDescriptor{}, err
+		}
+	}
+	if !hasSource {
+		return OutputDescriptor{}, errors.New("missing descriptor source")
+	}
+	if dec.Len() > 0 {
+		return OutputDescriptor{}, fmt.Errorf("%w: %d bytes", psbt.ErrTrailingData, dec.Len())
+	}
+	return desc, nil
+}
+
+func decodeCBORKeys(dec *cbor.Decoder, keys *[]psbt.ExtendedKey) error {
+	n, err := dec.Array()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		t, err := dec.Tag()
+		if err != nil {
+			return err
+		}
+		if t != v2Tags.hdkey {
+			return fmt.Errorf("key %d: unsupported tag %d", i, t)
+		}
+		k, _, hasChildren, err := readHDKey(dec, v2Tags)
+		if err != nil {
+			return fmt.Errorf("key %d: %w", i, err)
+		}
+		if hasChildren {
+			// Derivations belong in the source text.
+			return fmt.Errorf("key %d: unexpected children", i)
+		}
+		*keys = append(*keys, k)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package cod
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// This file implements the descriptor checksum from BIP-380.
+
+const (
+	checksumInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	checksumLen     = 8
+)
+
+// DescriptorChecksum computes the 8 character BIP-380 checksum of
+// desc, which must not include a checksum itself. The empty string
+// is returned if desc contains characters outside the descriptor
+// character set.
+func DescriptorChecksum(desc string) string {
+	c := uint64(1)
+	cls, clsCount := 0, 0
+	for _, ch := range desc {
+		pos := strings.IndexRune(checksumInputCharset, ch)
+		if pos == -1 {
+			return ""
+		}
+		// Emit a symbol for the position inside the group, for
+		// every character.
+		c = checksumPolymod(c, uint64(pos&31))
+		// Accumulate the group numbers.
+		cls = cls*3 + pos>>5
+		clsCount++
+		if clsCount == 3 {
+			// Emit an extra symbol representing the group
+			// numbers, for every 3 characters.
+			c = checksumPolymod(c, uint64(cls))
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = checksumPolymod(c, uint64(cls))
+	}
+	// Shift further to determine the checksum.
+	for i := 0; i < checksumLen; i++ {
+		c = checksumPolymod(c, 0)
+	}
+	// Prevent appending zeroes from not affecting the checksum.
+	c ^= 1
+	sum := make([]byte, checksumLen)
+	for i := range sum {
+		sum[i] = checksumCharset[(c>>(5*(7-i)))&31]
+	}
+	return string(sum)
+}
+
+func checksumPolymod(c, val uint64) uint64 {
+	c0 := c >> 35
+	c = (c&0x7ffffffff)<<5 ^ val
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// ErrInvalidChecksum is returned when a descriptor checksum
+// doesn't match the descriptor.
+var ErrInvalidChecksum = errors.New("invalid descriptor checksum")
+
+// VerifyChecksum verifies the checksum of desc, if present.
+func VerifyChecksum(desc string) error {
+	desc, sum, ok := strings.Cut(desc, "#")
+	if !ok {
+		return nil
+	}
+	if want := DescriptorChecksum(desc); want == "" || sum != want {
+		return fmt.Errorf("%w: %q", ErrInvalidChecksum, sum)
+	}
+	return nil
+}
+
+// WithChecksum returns the descriptor followed by '#' and its
+// checksum, replacing any existing checksum.
+func (d OutputDescriptor) WithChecksum() (string, error) {
+	desc, _, _ := strings.Cut(d.Descriptor, "#")
+	sum := DescriptorChecksum(desc)
+	if sum == "" {
+		return "", errors.New("serdesc: invalid character in descriptor")
+	}
+	return desc + "#" + sum, nil
+}
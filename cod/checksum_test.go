@@ -0,0 +1,64 @@
+package cod
+
+import (
+	"errors"
+	"testing"
+)
+
+// The vectors are from BIP-380.
+func TestDescriptorChecksum(t *testing.T) {
+	tests := []struct {
+		desc string
+		sum  string
+	}{
+		{"raw(deadbeef)", "89f8spxm"},
+		{"pkh([d34db33f/44'/0'/0']xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL/1/*)", "ml40v0wf"},
+		{"raw(Ü)", ""},
+	}
+	for _, test := range tests {
+		if got := DescriptorChecksum(test.desc); got != test.sum {
+			t.Errorf("DescriptorChecksum(%s) = %q, want %q", test.desc, got, test.sum)
+		}
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	tests := []struct {
+		desc  string
+		valid bool
+	}{
+		{"raw(deadbeef)#89f8spxm", true},
+		{"raw(deadbeef)", true},
+		{"pkh([d34db33f/44'/0'/0']xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL/1/*)#ml40v0wf", true},
+		// Missing checksum.
+		{"raw(deadbeef)#", false},
+		// Too long checksum.
+		{"raw(deadbeef)#89f8spxmx", false},
+		// Too short checksum.
+		{"raw(deadbeef)#89f8spx", false},
+		// Error in payload.
+		{"raw(deedbeef)#89f8spxm", false},
+		// Error in checksum.
+		{"raw(deadbeef)##9f8spxm", false},
+		// Invalid characters in payload.
+		{"raw(Ü)#00000000", false},
+	}
+	for _, test := range tests {
+		err := VerifyChecksum(test.desc)
+		if test.valid && err != nil {
+			t.Errorf("VerifyChecksum(%s): %v", test.desc, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidChecksum) {
+			t.Errorf("VerifyChecksum(%s) returned %v, want %v", test.desc, err, ErrInvalidChecksum)
+		}
+	}
+}
+
+func TestWithChecksum(t *testing.T) {
+	for _, desc := range []string{"raw(deadbeef)", "raw(deadbeef)#00000000"} {
+		got, err := OutputDescriptor{Descriptor: desc}.WithChecksum()
+		if err != nil || got != "raw(deadbeef)#89f8spxm" {
+			t.Errorf("WithChecksum of %s = %s, %v", desc, got, err)
+		}
+	}
+}
@@ -2,9 +2,13 @@ package cod
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"slices"
 
 	"github.com/seedhammer/bip-serialized-descriptors/psbt"
 )
@@ -13,7 +17,7 @@ import (
 // with no other external dependency than the BIP-174 encoding and decoding
 // implemented in psbt.go.
 
-const HardenedKeyStart = 0x80000000 // 2^31
+const HardenedKeyStart = psbt.HardenedKeyStart
 
 const SerializeDescMagic = "desc\xff"
 
@@ -22,96 +26,457 @@ const (
 	GLOBAL_OUTPUT_DESCRIPTOR = 0x00
 	// Field type for name.
 	GLOBAL_NAME = 0x01
+	// Field type for the integrity checksum, an extension to the
+	// format. See EncodeOptions.Integrity.
+	GLOBAL_INTEGRITY = 0x02
+	// Field type for the optional witness or redeem script of the
+	// first address, an extension to the format. See
+	// OutputDescriptor.FirstScript.
+	GLOBAL_FIRST_SCRIPT = 0x03
 
 	// Field type for extended key, encoded as PSBT_GLOBAL_XPUB.
 	KEY_XPUB = 0x00
+	// Field type for the optional label of the key, an extension
+	// to the format. See OutputDescriptor.KeyLabels.
+	KEY_LABEL = 0x01
 )
 
+// maxPathLen is the maximum length of a key derivation path, limited
+// by the single byte depth of BIP-32 extended keys.
+const maxPathLen = 255
+
+// OutputDescriptor is a descriptor template with its keys.
 type OutputDescriptor struct {
-	Name       string
+	// Name is the optional name of the descriptor. An empty name
+	// is not encoded.
+	Name string
+	// Descriptor is the template, where keys are referenced by @-prefixed
+	// indices into Keys. A serialized descriptor carries a single template,
+	// so receive and change chains are expressed with BIP-389 multipath
+	// groups such as @0/<0;1>/* (or the /** shorthand). Use SplitMultipath
+	// to obtain a descriptor for each chain.
 	Descriptor string
 	Keys       []psbt.ExtendedKey
+	// KeyLabels are the optional labels of Keys, such as the names
+	// of cosigners. If not empty, it has a label for each key. Empty
+	// labels are not encoded.
+	KeyLabels []string
+	// FirstScript is the optional witness or redeem script of the
+	// first address, as returned by Script(0, 0), for verifiers to
+	// compare against their own derivation. It is encoded if not
+	// empty.
+	FirstScript []byte
+	// Unknown holds global entries of unknown type, preserved
+	// for forward compatibility.
+	Unknown []psbt.Entry
+}
+
+// OutputDescriptorFromStrings returns the descriptor with the given
+// name and template, and keys parsed from their origin form such as
+// "[dc567276/48'/0'/0'/2']xpub...".
+func OutputDescriptorFromStrings(name, descriptor string, keys []string) (OutputDescriptor, error) {
+	desc := OutputDescriptor{Name: name, Descriptor: descriptor}
+	for i, s := range keys {
+		k, err := psbt.ParseExtendedKey(s)
+		if err != nil {
+			return OutputDescriptor{}, fmt.Errorf("serdesc: key @%d: %w", i, err)
+		}
+		desc.Keys = append(desc.Keys, k)
+	}
+	return desc, nil
 }
 
+// integrityLen is the length of the integrity checksum.
+const integrityLen = 4
+
+// ErrMissingDescriptor is returned by Decode when the global map
+// has no GLOBAL_OUTPUT_DESCRIPTOR entry.
+var ErrMissingDescriptor = errors.New("missing output descriptor")
+
+// ErrIntegrity is returned when the integrity checksum of a
+// serialized descriptor doesn't match its contents.
+var ErrIntegrity = errors.New("integrity checksum mismatch")
+
+// EncodeOptions controls optional extensions of the encoding.
+type EncodeOptions struct {
+	// Integrity adds a GLOBAL_INTEGRITY field holding the first 4
+	// bytes of the SHA256 hash of the serialization with the field
+	// excluded. Decoders that don't know the field ignore it.
+	Integrity bool
+}
+
+// Encode serializes desc without extensions.
 func Encode(desc OutputDescriptor) ([]byte, error) {
-	// Encode magic.
+	return EncodeOptions{}.Encode(desc)
+}
+
+// EncodeWriter serializes desc to w without extensions.
+func EncodeWriter(w io.Writer, desc OutputDescriptor) error {
+	return EncodeOptions{}.EncodeWriter(w, desc)
+}
+
+// EncodedSize returns the length of the serialization of desc
+// without extensions.
+func EncodedSize(desc OutputDescriptor) int {
+	return EncodeOptions{}.EncodedSize(desc)
+}
+
+// EncodedSize returns the length of the serialization of desc, without
+// encoding it. For descriptors accepted by Encode it equals the length
+// of the result.
+func (o EncodeOptions) EncodedSize(desc OutputDescriptor) int {
+	// The global map.
+	size := len(SerializeDescMagic)
+	if desc.Name != "" {
+		size += psbt.Entry{Key: []byte{GLOBAL_NAME}, Val: []byte(desc.Name)}.Len()
+	}
+	size += psbt.Entry{Key: []byte{GLOBAL_OUTPUT_DESCRIPTOR}, Val: []byte(desc.Descriptor)}.Len()
+	if len(desc.FirstScript) > 0 {
+		size += psbt.Entry{Key: []byte{GLOBAL_FIRST_SCRIPT}, Val: desc.FirstScript}.Len()
+	}
+	for _, e := range desc.Unknown {
+		size += e.Len()
+	}
+	if o.Integrity {
+		size += psbt.Entry{Key: []byte{GLOBAL_INTEGRITY}, Val: make([]byte, integrityLen)}.Len()
+	}
+	size++
+	// A map for each key.
+	for i, k := range desc.Keys {
+		keyLen := 1 + len(k.Key)
+		valLen := 4 + 4*len(k.Path)
+		size += psbt.VarIntSize(uint64(keyLen)) + keyLen + psbt.VarIntSize(uint64(valLen)) + valLen
+		if l := desc.keyLabel(i); l != "" {
+			size += psbt.Entry{Key: []byte{KEY_LABEL}, Val: []byte(l)}.Len()
+		}
+		size++
+	}
+	return size
+}
+
+// Encode serializes desc.
+func (o EncodeOptions) Encode(desc OutputDescriptor) ([]byte, error) {
 	buf := new(bytes.Buffer)
-	buf.Write([]byte(SerializeDescMagic))
-
-	// Encode global map describing the output descriptor.
-	psbt.Entry{
-		Key: []byte{GLOBAL_NAME},
-		Val: []byte(desc.Name),
-	}.Write(buf)
-	psbt.Entry{
+	if err := o.EncodeWriter(buf, desc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo serializes desc to w without extensions. It implements
+// io.WriterTo.
+func (desc OutputDescriptor) WriteTo(w io.Writer) (int64, error) {
+	return EncodeOptions{}.writeTo(w, desc)
+}
+
+// EncodeWriter serializes desc to w, one map at a time. The descriptor
+// is validated before anything is written.
+func (o EncodeOptions) EncodeWriter(w io.Writer, desc OutputDescriptor) error {
+	_, err := o.writeTo(w, desc)
+	return err
+}
+
+// writeTo is EncodeWriter that also returns the number of bytes
+// written.
+func (o EncodeOptions) writeTo(w io.Writer, desc OutputDescriptor) (int64, error) {
+	// Encode global map describing the output descriptor. The name
+	// is optional and omitted if empty.
+	var global psbt.Map
+	if desc.Name != "" {
+		global = append(global, psbt.Entry{
+			Key: []byte{GLOBAL_NAME},
+			Val: []byte(desc.Name),
+		})
+	}
+	global = append(global, psbt.Entry{
 		Key: []byte{GLOBAL_OUTPUT_DESCRIPTOR},
 		Val: []byte(desc.Descriptor),
-	}.Write(buf)
-	buf.WriteByte(0x00)
+	})
+	seen := make(map[string]bool)
+	for i, e := range desc.Unknown {
+		if len(e.Key) == 0 {
+			return 0, fmt.Errorf("serdesc: unknown entry %d: empty key", i)
+		}
+		switch e.Key[0] {
+		case GLOBAL_NAME, GLOBAL_OUTPUT_DESCRIPTOR, GLOBAL_INTEGRITY, GLOBAL_FIRST_SCRIPT:
+			return 0, fmt.Errorf("serdesc: unknown entry %d: known key type %#x", i, e.Key[0])
+		}
+		if seen[string(e.Key)] {
+			return 0, fmt.Errorf("serdesc: unknown entry %d: %w %#x", i, psbt.ErrDuplicateKey, e.Key)
+		}
+		seen[string(e.Key)] = true
+	}
+	if len(desc.FirstScript) > 0 {
+		global = append(global, psbt.Entry{
+			Key: []byte{GLOBAL_FIRST_SCRIPT},
+			Val: desc.FirstScript,
+		})
+	}
+	global = append(global, desc.Unknown...)
+	if n := len(desc.KeyLabels); n > 0 && n != len(desc.Keys) {
+		return 0, fmt.Errorf("serdesc: %d key labels for %d keys", n, len(desc.Keys))
+	}
+	for i, k := range desc.Keys {
+		if len(k.PubKey()) == 0 {
+			return 0, fmt.Errorf("serdesc: key @%d: invalid extended key of %d bytes", i, len(k.Key))
+		}
+		if len(k.Path) > maxPathLen {
+			return 0, fmt.Errorf("serdesc: key @%d: derivation path of %d elements exceeds %d", i, len(k.Path), maxPathLen)
+		}
+	}
+	if o.Integrity {
+		sum, err := integrityChecksum(desc)
+		if err != nil {
+			return 0, err
+		}
+		global = append(global, psbt.Entry{
+			Key: []byte{GLOBAL_INTEGRITY},
+			Val: sum,
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(SerializeDescMagic)
+	psbt.EncodeMap(buf, global)
+	n, err := w.Write(buf.Bytes())
+	total := int64(n)
+	if err != nil {
+		return total, fmt.Errorf("serdesc: %w", err)
+	}
 
 	// Write a map for each key.
-	for _, k := range desc.Keys {
+	for i, k := range desc.Keys {
+		buf.Reset()
 		var mfpAndPath []byte
 		mfpAndPath = binary.BigEndian.AppendUint32(mfpAndPath, k.MasterFingerprint)
 		for _, p := range k.Path {
 			mfpAndPath = binary.LittleEndian.AppendUint32(mfpAndPath, p)
 		}
-		psbt.Entry{
-			Key: append([]byte{KEY_XPUB}, k.Key...),
-			Val: mfpAndPath,
-		}.Write(buf)
-		buf.WriteByte(0x00)
+		keyMap := psbt.Map{
+			{
+				Key: append([]byte{KEY_XPUB}, k.Key...),
+				Val: mfpAndPath,
+			},
+		}
+		if l := desc.keyLabel(i); l != "" {
+			keyMap = append(keyMap, psbt.Entry{
+				Key: []byte{KEY_LABEL},
+				Val: []byte(l),
+			})
+		}
+		psbt.EncodeMap(buf, keyMap)
+		n, err := w.Write(buf.Bytes())
+		total += int64(n)
+		if err != nil {
+			return total, fmt.Errorf("serdesc: %w", err)
+		}
 	}
+	return total, nil
+}
 
-	return buf.Bytes(), nil
+// DecodeOptions controls the optional validation performed
+// when decoding.
+type DecodeOptions struct {
+	// VerifyChecksum enables verification of the descriptor
+	// checksum, if present.
+	VerifyChecksum bool
+	// VerifySyntax enables the syntax check of the descriptor
+	// template, see VerifySyntax.
+	VerifySyntax bool
+	// VerifyIntegrity enables verification of the GLOBAL_INTEGRITY
+	// checksum, if present.
+	VerifyIntegrity bool
+	// Strict rejects global and key map entries of unknown type
+	// with ErrUnknownField, instead of preserving or skipping them
+	// for forward compatibility.
+	Strict bool
 }
 
+// ErrUnknownField is returned by strict decoding for entries
+// of unknown type.
+var ErrUnknownField = errors.New("unknown field type")
+
+// Decode decodes a serialized descriptor without optional
+// validation.
 func Decode(data []byte) (OutputDescriptor, error) {
-	const psbtMagic = "psbt\xff"
+	return DecodeOptions{}.Decode(data)
+}
+
+// Decode decodes a serialized descriptor.
+func (o DecodeOptions) Decode(data []byte) (OutputDescriptor, error) {
+	if len(data) < len(SerializeDescMagic) {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: %w: input too short (%d bytes)", psbt.ErrInvalidMagic, len(data))
+	}
 	if !bytes.HasPrefix(data, []byte(SerializeDescMagic)) {
-		return OutputDescriptor{}, errors.New("serdesc: invalid magic")
+		return OutputDescriptor{}, fmt.Errorf("serdesc: %w", psbt.ErrInvalidMagic)
 	}
-	data = data[len(psbtMagic):]
+	data = data[len(SerializeDescMagic):]
+	desc, err := o.decode(func() (psbt.Map, int, error) {
+		m, n, err := psbt.DecodeMap(data)
+		data = data[n:]
+		return m, n, err
+	})
+	if err != nil {
+		return OutputDescriptor{}, err
+	}
+	return desc, nil
+}
 
+// decode decodes the maps following the magic, reading each
+// map with next.
+func (o DecodeOptions) decode(next func() (psbt.Map, int, error)) (OutputDescriptor, error) {
 	// Read global map.
-	m, n, err := psbt.DecodeMap(data)
-	data = data[n:]
+	m, _, err := next()
 	if err != nil {
 		return OutputDescriptor{}, fmt.Errorf("serdesc: %w", err)
 	}
 	var desc OutputDescriptor
+	var integrity []byte
+	var labels []string
+	hasDescriptor := false
+	// The integrity checksum covers the input with the GLOBAL_INTEGRITY
+	// entry excluded. Decoding preserves the order of entries and
+	// rejects non-canonical lengths, so writing the decoded maps
+	// reproduces the input exactly.
+	h := sha256.New()
+	h.Write([]byte(SerializeDescMagic))
 	for _, e := range m {
+		if e.Key[0] != GLOBAL_INTEGRITY {
+			e.WriteTo(h)
+		}
 		switch k := e.Key[0]; k {
 		case GLOBAL_NAME:
 			desc.Name = string(e.Val)
+		case GLOBAL_INTEGRITY:
+			integrity = e.Val
+		case GLOBAL_FIRST_SCRIPT:
+			desc.FirstScript = e.Val
 		case GLOBAL_OUTPUT_DESCRIPTOR:
 			desc.Descriptor = string(e.Val)
+			hasDescriptor = true
+		default:
+			if o.Strict {
+				return OutputDescriptor{}, fmt.Errorf("serdesc: %w: global %#.2x", ErrUnknownField, k)
+			}
+			desc.Unknown = append(desc.Unknown, e)
+			continue
 		}
+		// Known fields carry no key data.
+		if len(e.Key) != 1 {
+			return OutputDescriptor{}, fmt.Errorf("serdesc: invalid key %#x", e.Key)
+		}
+	}
+	h.Write([]byte{0x00})
+	// The name is optional, the descriptor is not.
+	if !hasDescriptor {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: %w", ErrMissingDescriptor)
 	}
 
-	// Read keys.
-	for {
-		m, n, err := psbt.DecodeMap(data)
-		data = data[n:]
+	// Read keys. The key maps extend to the end of the input, but an
+	// empty map also ends them: some encoders write an extra separator
+	// after the last key map. Any number of such trailing separators
+	// is accepted, but anything else following them is trailing data.
+	for ended := false; ; {
+		m, n, err := next()
 		if err != nil {
+			if ended && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+				return OutputDescriptor{}, fmt.Errorf("serdesc: %w: %w", psbt.ErrTrailingData, err)
+			}
 			return OutputDescriptor{}, fmt.Errorf("serdesc: %w", err)
 		}
 		if n == 0 {
 			// No more keys.
 			break
 		}
-		for i, e := range m {
-			var key psbt.ExtendedKey
-			switch k := e.Key[0]; k {
-			case KEY_XPUB:
-				k, err := psbt.DecodePSBTXpub(e)
-				if err != nil {
-					return OutputDescriptor{}, fmt.Errorf("serdesc: invalid key at index %d: %w", i, err)
+		for _, e := range m {
+			e.WriteTo(h)
+		}
+		h.Write([]byte{0x00})
+		if len(m) == 0 {
+			ended = true
+			continue
+		}
+		if ended {
+			return OutputDescriptor{}, fmt.Errorf("serdesc: %w: key map after empty map", psbt.ErrTrailingData)
+		}
+		// Unknown key types are skipped for forward compatibility.
+		if o.Strict {
+			for _, e := range m {
+				if e.Key[0] != KEY_XPUB && e.Key[0] != KEY_LABEL {
+					return OutputDescriptor{}, fmt.Errorf("serdesc: %w: key %#.2x at index %d", ErrUnknownField, e.Key[0], len(desc.Keys))
 				}
-				key = k
+			}
+		}
+		// Every key map holds a key, so that the map order determines
+		// the @N indices.
+		xpubs := m.GetAll(KEY_XPUB)
+		if len(xpubs) == 0 {
+			return OutputDescriptor{}, fmt.Errorf("serdesc: key map without key at index %d", len(desc.Keys))
+		}
+		label := ""
+		switch entries := m.GetAll(KEY_LABEL); len(entries) {
+		case 0:
+		case 1:
+			// A label applies to the single key of its map.
+			if len(entries[0].Key) != 1 || len(xpubs) != 1 {
+				return OutputDescriptor{}, fmt.Errorf("serdesc: invalid label at index %d", len(desc.Keys))
+			}
+			label = string(entries[0].Val)
+		default:
+			return OutputDescriptor{}, fmt.Errorf("serdesc: %d labels at index %d", len(entries), len(desc.Keys))
+		}
+		for _, e := range xpubs {
+			key, err := psbt.DecodePSBTXpub(e)
+			if err != nil {
+				return OutputDescriptor{}, fmt.Errorf("serdesc: invalid key at index %d: %w", len(desc.Keys), err)
 			}
 			desc.Keys = append(desc.Keys, key)
+			labels = append(labels, label)
+		}
+	}
+	// Labels are optional; leave KeyLabels empty without any.
+	if hasLabels(labels) {
+		desc.KeyLabels = labels
+	}
+	if o.VerifyIntegrity && integrity != nil {
+		if !bytes.Equal(h.Sum(nil)[:integrityLen], integrity) {
+			return OutputDescriptor{}, fmt.Errorf("serdesc: %w", ErrIntegrity)
+		}
+	}
+	if o.VerifyChecksum {
+		if err := VerifyChecksum(desc.Descriptor); err != nil {
+			return OutputDescriptor{}, fmt.Errorf("serdesc: %w", err)
+		}
+	}
+	if o.VerifySyntax {
+		if err := VerifySyntax(desc.Descriptor); err != nil {
+			return OutputDescriptor{}, fmt.Errorf("serdesc: %w", err)
 		}
 	}
 	return desc, nil
 }
+
+// keyLabel returns the label of key i, or the empty string if
+// it has none.
+func (d OutputDescriptor) keyLabel(i int) string {
+	if i < len(d.KeyLabels) {
+		return d.KeyLabels[i]
+	}
+	return ""
+}
+
+// hasLabels reports whether any of labels is not empty.
+func hasLabels(labels []string) bool {
+	return slices.ContainsFunc(labels, func(l string) bool { return l != "" })
+}
+
+// integrityChecksum returns the GLOBAL_INTEGRITY value of desc, the
+// checksum of its encoding without the field.
+func integrityChecksum(desc OutputDescriptor) ([]byte, error) {
+	enc, err := Encode(desc)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(enc)
+	return sum[:integrityLen], nil
+}
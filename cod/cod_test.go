@@ -0,0 +1,255 @@
+package cod
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+const testXpub = "[dc567276/48'/0'/0'/2']xpub6DiYrfRwNnjeX4vHsWMajJVFKrbEEnu8gAW9vDuQzgTWEsEHE16sGWeXXUV1LBWQE1yCTmeprSNcqZ3W74hqVdgDbtYHUv3eM4W2TEUhpan"
+
+func testKey(t testing.TB) psbt.ExtendedKey {
+	t.Helper()
+	k, err := psbt.ParseExtendedKey(testXpub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// xpubEntry returns the KEY_XPUB entry of k.
+func xpubEntry(k psbt.ExtendedKey) psbt.Entry {
+	val := binary.BigEndian.AppendUint32(nil, k.MasterFingerprint)
+	for _, p := range k.Path {
+		val = binary.LittleEndian.AppendUint32(val, p)
+	}
+	return psbt.Entry{Key: append([]byte{KEY_XPUB}, k.Key...), Val: val}
+}
+
+// serialize returns the magic followed by maps, with entries in the
+// given order.
+func serialize(maps ...psbt.Map) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString(SerializeDescMagic)
+	for _, m := range maps {
+		for _, e := range m {
+			e.Write(buf)
+		}
+		buf.WriteByte(0x00)
+	}
+	return buf.Bytes()
+}
+
+// withIntegrity returns the serialization of maps with a GLOBAL_INTEGRITY
+// entry appended to the first map.
+func withIntegrity(maps ...psbt.Map) []byte {
+	sum := sha256.Sum256(serialize(maps...))
+	maps[0] = append(maps[0], psbt.Entry{Key: []byte{GLOBAL_INTEGRITY}, Val: sum[:integrityLen]})
+	return serialize(maps...)
+}
+
+func TestIntegrity(t *testing.T) {
+	k := testKey(t)
+	desc := psbt.Entry{Key: []byte{GLOBAL_OUTPUT_DESCRIPTOR}, Val: []byte("wpkh(@0/<0;1>/*)")}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{
+			"canonical",
+			withIntegrity(psbt.Map{desc}, psbt.Map{xpubEntry(k)}),
+		},
+		{
+			"unknown key map entry",
+			withIntegrity(psbt.Map{desc}, psbt.Map{xpubEntry(k), {Key: []byte{0x7f}, Val: []byte{1}}}),
+		},
+		{
+			"unsorted global map",
+			withIntegrity(psbt.Map{desc, {Key: []byte{GLOBAL_NAME}, Val: []byte("name")}}, psbt.Map{xpubEntry(k)}),
+		},
+		{
+			"empty name",
+			withIntegrity(psbt.Map{{Key: []byte{GLOBAL_NAME}}, desc}, psbt.Map{xpubEntry(k)}),
+		},
+	}
+	verify := DecodeOptions{VerifyIntegrity: true}
+	for _, test := range tests {
+		if _, err := verify.Decode(test.data); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		// Corrupt the last byte of the descriptor.
+		corrupt := bytes.Clone(test.data)
+		i := bytes.Index(corrupt, desc.Val) + len(desc.Val) - 1
+		corrupt[i] ^= 0x01
+		if _, err := verify.Decode(corrupt); !errors.Is(err, ErrIntegrity) {
+			t.Errorf("%s: corrupted input decoded with %v, want %v", test.name, err, ErrIntegrity)
+		}
+		if _, err := Decode(corrupt); err != nil {
+			t.Errorf("%s: corrupted input without VerifyIntegrity: %v", test.name, err)
+		}
+	}
+}
+
+func TestEncodeIntegrity(t *testing.T) {
+	k := testKey(t)
+	k.Path = []uint32{84 + HardenedKeyStart, HardenedKeyStart, HardenedKeyStart}
+	d, err := NewSingleSig(psbt.P2WPKH, k, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Name = "wallet"
+	enc, err := EncodeOptions{Integrity: true}.Encode(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := Encode(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(plain)
+	entry := psbt.Entry{Key: []byte{GLOBAL_INTEGRITY}, Val: sum[:integrityLen]}
+	buf := new(bytes.Buffer)
+	entry.Write(buf)
+	if !bytes.Contains(enc, buf.Bytes()) {
+		t.Errorf("encoding %x lacks integrity entry %x", enc, buf.Bytes())
+	}
+	if _, err := (DecodeOptions{VerifyIntegrity: true}).Decode(enc); err != nil {
+		t.Error(err)
+	}
+	if _, err := (DecodeOptions{VerifyIntegrity: true}).DecodeReader(bytes.NewReader(enc)); err != nil {
+		t.Error(err)
+	}
+}
+
+func FuzzCodRoundTrip(f *testing.F) {
+	f.Add("", "wpkh(@0/<0;1>/*)", uint32(0), []byte{}, "")
+	f.Add("wallet", "wsh(sortedmulti(1,@0/**))", uint32(0xdc567276), []byte{0x30, 0x00, 0x00, 0x80}, "cosigner")
+	f.Add("\x00", "tr(@0/\x00)", uint32(0), []byte{0x00, 0x00, 0x00, 0x00}, "\x00")
+	f.Fuzz(func(t *testing.T, name, tmpl string, mfp uint32, path []byte, label string) {
+		k := testKey(t)
+		k.MasterFingerprint = mfp
+		k.Path = nil
+		for len(path) >= 4 && len(k.Path) < maxPathLen {
+			k.Path = append(k.Path, binary.LittleEndian.Uint32(path))
+			path = path[4:]
+		}
+		d := OutputDescriptor{
+			Name:       name,
+			Descriptor: tmpl,
+			Keys:       []psbt.ExtendedKey{k},
+		}
+		if label != "" {
+			d.KeyLabels = []string{label}
+		}
+		enc, err := Encode(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Decode(enc)
+		if err != nil {
+			t.Fatalf("Decode(%x): %v", enc, err)
+		}
+		if !reflect.DeepEqual(got, d) {
+			t.Errorf("round trip mismatch:\n%+v\n%+v", got, d)
+		}
+	})
+}
+
+func TestDecodeShort(t *testing.T) {
+	for n := 0; n < len(SerializeDescMagic); n++ {
+		if _, err := Decode([]byte(SerializeDescMagic[:n])); !errors.Is(err, psbt.ErrInvalidMagic) {
+			t.Errorf("Decode(%x) returned %v, want %v", SerializeDescMagic[:n], err, psbt.ErrInvalidMagic)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte(SerializeDescMagic[:3]))
+	f.Add([]byte(SerializeDescMagic))
+	f.Add([]byte(SerializeDescMagic + "\x00"))
+	f.Add(serialize(psbt.Map{{Key: []byte{GLOBAL_OUTPUT_DESCRIPTOR}, Val: []byte("wpkh(@0/**)")}}, psbt.Map{xpubEntry(testKey(f))}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(data)
+		DecodeOptions{VerifyChecksum: true, VerifySyntax: true, VerifyIntegrity: true, Strict: true}.Decode(data)
+	})
+}
+
+func TestTrailingSeparators(t *testing.T) {
+	k := testKey(t)
+	global := psbt.Map{{Key: []byte{GLOBAL_OUTPUT_DESCRIPTOR}, Val: []byte("wpkh(@0/**)")}}
+	keyMap := psbt.Map{xpubEntry(k)}
+	tests := []struct {
+		name  string
+		data  []byte
+		valid bool
+	}{
+		{"no separator", serialize(global, keyMap), true},
+		{"one separator", serialize(global, keyMap, psbt.Map{}), true},
+		{"several separators", serialize(global, keyMap, psbt.Map{}, psbt.Map{}, psbt.Map{}), true},
+		{"key map after separator", serialize(global, keyMap, psbt.Map{}, keyMap), false},
+		{"garbage after separator", append(serialize(global, keyMap, psbt.Map{}), 0x05, 0x01), false},
+		{"garbage after separators", append(serialize(global, keyMap, psbt.Map{}, psbt.Map{}), 0xff), false},
+	}
+	for _, test := range tests {
+		for _, decode := range []func([]byte) (OutputDescriptor, error){
+			Decode,
+			func(data []byte) (OutputDescriptor, error) { return DecodeReader(bytes.NewReader(data)) },
+		} {
+			d, err := decode(test.data)
+			if !test.valid {
+				if !errors.Is(err, psbt.ErrTrailingData) {
+					t.Errorf("%s: decoded %d keys with %v, want %v", test.name, len(d.Keys), err, psbt.ErrTrailingData)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+				continue
+			}
+			if len(d.Keys) != 1 {
+				t.Errorf("%s: %d keys, want 1", test.name, len(d.Keys))
+			}
+		}
+	}
+}
+
+func TestKeyMapWithoutKey(t *testing.T) {
+	k := testKey(t)
+	global := psbt.Map{{Key: []byte{GLOBAL_OUTPUT_DESCRIPTOR}, Val: []byte("wsh(multi(1,@0/**,@1/**))")}}
+	// A key map of only unknown entries would shift the index of
+	// the following key.
+	data := serialize(global, psbt.Map{{Key: []byte{0x7f}, Val: []byte{1}}}, psbt.Map{xpubEntry(k)})
+	if d, err := Decode(data); err == nil {
+		t.Errorf("decoded %d keys from a key map without key", len(d.Keys))
+	}
+	if d, err := DecodeReader(bytes.NewReader(data)); err == nil {
+		t.Errorf("DecodeReader decoded %d keys from a key map without key", len(d.Keys))
+	}
+}
+
+func TestDuplicateLabels(t *testing.T) {
+	k := testKey(t)
+	global := psbt.Map{{Key: []byte{GLOBAL_OUTPUT_DESCRIPTOR}, Val: []byte("wpkh(@0/**)")}}
+	label := psbt.Entry{Key: []byte{KEY_LABEL}, Val: []byte("Alice")}
+	tests := []struct {
+		name string
+		m    psbt.Map
+	}{
+		{"key data", psbt.Map{xpubEntry(k), {Key: []byte{KEY_LABEL, 0x00}, Val: []byte("Alice")}}},
+		{"second label with key data", psbt.Map{xpubEntry(k), label, {Key: []byte{KEY_LABEL, 0x00}, Val: []byte("Bob")}}},
+	}
+	for _, test := range tests {
+		data := serialize(global, test.m)
+		for _, o := range []DecodeOptions{{}, {Strict: true}} {
+			if d, err := o.Decode(data); err == nil {
+				t.Errorf("%s: strict %v: decoded labels %q", test.name, o.Strict, d.KeyLabels)
+			}
+		}
+	}
+}
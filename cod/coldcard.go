@@ -0,0 +1,69 @@
+package cod
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// This file implements export to the Coldcard multisig setup file.
+
+// coldcardFormats maps script wrappers to Coldcard address formats.
+var coldcardFormats = map[string]string{
+	"sh":     "P2SH",
+	"wsh":    "P2WSH",
+	"sh-wsh": "P2SH-P2WSH",
+}
+
+// maxColdcardNameLen is the maximum length of Coldcard wallet names.
+const maxColdcardNameLen = 20
+
+// ExportColdcard returns the descriptor in the text format of Coldcard
+// multisig setup files. The descriptor must be a sortedmulti, and
+// its keys must share a derivation path. Descriptors using multi are
+// rejected, because the text format has no way to specify the key
+// order and Coldcard always sorts the keys as specified by BIP-67.
+// The name is required by Coldcard, and must be at most 20 printable
+// ASCII characters.
+func ExportColdcard(desc OutputDescriptor) (string, error) {
+	if desc.Name == "" {
+		return "", errors.New("serdesc: coldcard: missing name")
+	}
+	if len(desc.Name) > maxColdcardNameLen {
+		return "", fmt.Errorf("serdesc: coldcard: name %q longer than %d characters", desc.Name, maxColdcardNameLen)
+	}
+	for _, c := range []byte(desc.Name) {
+		if c < 0x20 || c > 0x7e {
+			return "", fmt.Errorf("serdesc: coldcard: name %q contains non-printable or non-ASCII characters", desc.Name)
+		}
+	}
+	ms, err := desc.multisig()
+	if err != nil {
+		return "", err
+	}
+	format, ok := coldcardFormats[ms.wrappers]
+	if !ok || !ms.sorted {
+		return "", errors.New("serdesc: coldcard: not a sh, wsh or sh(wsh) sortedmulti descriptor")
+	}
+	var keys []psbt.ExtendedKey
+	for _, idx := range ms.keys {
+		k := desc.Keys[idx]
+		if len(keys) > 0 && !slices.Equal(k.Path, keys[0].Path) {
+			return "", fmt.Errorf("serdesc: coldcard: key @%d derivation %s differs from %s",
+				idx, psbt.FormatPath(k.Path), psbt.FormatPath(keys[0].Path))
+		}
+		keys = append(keys, k)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Name: %s\n", desc.Name)
+	fmt.Fprintf(&b, "Policy: %d of %d\n", ms.threshold, len(keys))
+	fmt.Fprintf(&b, "Derivation: %s\n", psbt.FormatPath(keys[0].Path))
+	fmt.Fprintf(&b, "Format: %s\n\n", format)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%.8X: %s\n", k.MasterFingerprint, k)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,47 @@
+package cod
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+func TestExportColdcard(t *testing.T) {
+	k := testKey(t)
+	keys := []psbt.ExtendedKey{k, k, k}
+	d, err := NewMultisig(psbt.P2WSH, 2, keys, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Name = "Satoshi's Stash"
+	got, err := ExportColdcard(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Name: Satoshi's Stash\nPolicy: 2 of 3\nDerivation: m/48'/0'/0'/2'\nFormat: P2WSH\n\nDC567276: "
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("ExportColdcard returned\n%s\nwant prefix\n%s", got, want)
+	}
+
+	for _, name := range []string{
+		"",
+		"123456789012345678901",
+		"wallet\nPolicy: 1 of 3",
+		"Sätoshi",
+	} {
+		d := d
+		d.Name = name
+		if _, err := ExportColdcard(d); err == nil {
+			t.Errorf("ExportColdcard with name %q succeeded", name)
+		}
+	}
+	unsorted, err := NewMultisig(psbt.P2WSH, 2, keys, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsorted.Name = d.Name
+	if _, err := ExportColdcard(unsorted); err == nil {
+		t.Error("ExportColdcard of a multi descriptor succeeded")
+	}
+}
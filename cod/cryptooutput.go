@@ -0,0 +1,306 @@
+package cod
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/seedhammer/bip-serialized-descriptors/internal/cbor"
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// This file implements conversion to and from the crypto-output CBOR
+// type of BCR-2020-010, with keys in the crypto-hdkey form of
+// BCR-2020-007. The result is the payload of ur:crypto-output URs, see
+// package ur.
+//
+// crypto-hdkey has no notation for BIP-389 multipath groups, so a
+// multipath descriptor is encoded as one crypto-output for each of its
+// paths by EncodeCryptoOutputs. Keys without children are bare keys.
+
+// CBOR tags of crypto-output script expressions.
+const (
+	tagSH          = 400
+	tagWSH         = 401
+	tagPK          = 402
+	tagPKH         = 403
+	tagWPKH        = 404
+	tagCombo       = 405
+	tagMulti       = 406
+	tagSortedMulti = 407
+	tagTR          = 409
+)
+
+// scriptTags maps single argument script functions to their tags.
+var scriptTags = map[string]uint64{
+	"sh":    tagSH,
+	"wsh":   tagWSH,
+	"pk":    tagPK,
+	"pkh":   tagPKH,
+	"wpkh":  tagWPKH,
+	"combo": tagCombo,
+	"tr":    tagTR,
+}
+
+// Map keys of the multi and sortedmulti expressions.
+const (
+	multiThreshold = 1
+	multiKeys      = 2
+)
+
+// EncodeCryptoOutput encodes desc in the crypto-output CBOR form. Only
+// descriptors of wrapped keys and multi/sortedmulti of keys, without
+// taproot script trees or multipath groups, are supported.
+func EncodeCryptoOutput(desc OutputDescriptor) ([]byte, error) {
+	tmpl, _, _ := strings.Cut(desc.Descriptor, "#")
+	if strings.Contains(tmpl, "<") || strings.Contains(tmpl, "/**") {
+		return nil, errors.New("serdesc: crypto-output: multipath descriptors are not supported, see EncodeCryptoOutputs")
+	}
+	e, err := parseExpr(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("serdesc: %w", err)
+	}
+	enc := new(cbor.Encoder)
+	if err := encodeScript(enc, desc.Keys, e); err != nil {
+		return nil, fmt.Errorf("serdesc: crypto-output: %w", err)
+	}
+	return enc.Bytes(), nil
+}
+
+// EncodeCryptoOutputs encodes desc as a crypto-output for each path
+// returned by SplitMultipath, such as the receive and change
+// descriptors of a descriptor with @0/<0;1>/* keys.
+func EncodeCryptoOutputs(desc OutputDescriptor) ([][]byte, error) {
+	descs, err := desc.SplitMultipath()
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([][]byte, len(descs))
+	for i, d := range descs {
+		desc.Descriptor = d
+		outputs[i], err = EncodeCryptoOutput(desc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return outputs, nil
+}
+
+func encodeScript(enc *cbor.Encoder, keys []psbt.ExtendedKey, e expr) error {
+	if tag, ok := scriptTags[e.name]; ok {
+		if len(e.args) != 1 {
+			return fmt.Errorf("%s: expected 1 argument, got %d", e.name, len(e.args))
+		}
+		enc.Tag(tag)
+		if arg := e.args[0]; arg.name != "" {
+			return encodeScript(enc, keys, arg)
+		}
+		return encodeHDKey(enc, keys, e.args[0].text)
+	}
+	switch e.name {
+	case "multi", "sortedmulti":
+		if len(e.args) < 2 {
+			return fmt.Errorf("%s: missing keys", e.name)
+		}
+		k, err := strconv.ParseUint(e.args[0].text, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%s: invalid threshold %q", e.name, e.args[0].text)
+		}
+		tag := uint64(tagMulti)
+		if e.name == "sortedmulti" {
+			tag = tagSortedMulti
+		}
+		enc.Tag(tag)
+		enc.Map(2)
+		enc.Uint(multiThreshold)
+		enc.Uint(k)
+		enc.Uint(multiKeys)
+		enc.Array(len(e.args) - 1)
+		for _, arg := range e.args[1:] {
+			if arg.name != "" {
+				return fmt.Errorf("%s: unexpected %s", e.name, arg.name)
+			}
+			if err := encodeHDKey(enc, keys, arg.text); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "":
+		return fmt.Errorf("unexpected argument %q", e.text)
+	default:
+		return fmt.Errorf("unsupported function %s", e.name)
+	}
+}
+
+func encodeHDKey(enc *cbor.Encoder, keys []psbt.ExtendedKey, arg string) error {
+	idx, suffix, err := parseKeyArg(arg)
+	if err != nil {
+		return err
+	}
+	if idx >= len(keys) {
+		return fmt.Errorf("key placeholder @%d out of range", idx)
+	}
+	children, err := parseChildren(suffix)
+	if err != nil {
+		return fmt.Errorf("key @%d: %w", idx, err)
+	}
+	if err := writeHDKey(enc, v1Tags, keys[idx], children, suffix != ""); err != nil {
+		return fmt.Errorf("key @%d: %w", idx, err)
+	}
+	return nil
+}
+
+// parseChildren parses a key derivation suffix such as /0/*.
+func parseChildren(suffix string) ([]pathComponent, error) {
+	if suffix == "" {
+		return nil, nil
+	}
+	var comps []pathComponent
+	for _, s := range strings.Split(suffix[1:], "/") {
+		var c pathComponent
+		if n, ok := strings.CutSuffix(s, "'"); ok {
+			s, c.hardened = n, true
+		} else if n, ok := strings.CutSuffix(s, "h"); ok {
+			s, c.hardened = n, true
+		}
+		if s == "*" {
+			c.wildcard = true
+		} else {
+			if strings.TrimLeft(s, "0123456789") != "" {
+				return nil, fmt.Errorf("unsupported derivation %q", s)
+			}
+			idx, err := strconv.ParseUint(s, 10, 31)
+			if err != nil {
+				return nil, fmt.Errorf("invalid derivation %q", s)
+			}
+			c.index = uint32(idx)
+		}
+		comps = append(comps, c)
+	}
+	return comps, nil
+}
+
+// DecodeCryptoOutput decodes a descriptor in the crypto-output CBOR
+// form. Keys are assigned placeholders in order of appearance.
+func DecodeCryptoOutput(data []byte) (OutputDescriptor, error) {
+	dec := cbor.NewDecoder(data)
+	var desc OutputDescriptor
+	tmpl, err := decodeScript(dec, &desc.Keys, 0)
+	if err != nil {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: crypto-output: %w", err)
+	}
+	if dec.Len() > 0 {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: crypto-output: %w: %d bytes", psbt.ErrTrailingData, dec.Len())
+	}
+	desc.Descriptor = tmpl
+	return desc, nil
+}
+
+// decodeScript decodes a script expression or key, and returns
+// its template.
+func decodeScript(dec *cbor.Decoder, keys *[]psbt.ExtendedKey, depth int) (string, error) {
+	if depth > maxExprDepth {
+		return "", errors.New("expression nested too deeply")
+	}
+	tag, err := dec.Tag()
+	if err != nil {
+		return "", err
+	}
+	for name, t := range scriptTags {
+		if t == tag {
+			arg, err := decodeScript(dec, keys, depth+1)
+			if err != nil {
+				return "", err
+			}
+			return name + "(" + arg + ")", nil
+		}
+	}
+	switch tag {
+	case tagMulti, tagSortedMulti:
+		return decodeMulti(dec, tag, keys)
+	case v1Tags.hdkey:
+		return decodeHDKey(dec, keys)
+	default:
+		return "", fmt.Errorf("unsupported tag %d", tag)
+	}
+}
+
+func decodeMulti(dec *cbor.Decoder, tag uint64, keys *[]psbt.ExtendedKey) (string, error) {
+	name := "multi"
+	if tag == tagSortedMulti {
+		name = "sortedmulti"
+	}
+	n, err := dec.Map()
+	if err != nil {
+		return "", err
+	}
+	var threshold uint64
+	var args []string
+	for i := 0; i < n; i++ {
+		k, err := dec.Uint()
+		if err != nil {
+			return "", err
+		}
+		switch k {
+		case multiThreshold:
+			threshold, err = dec.Uint()
+			if err != nil {
+				return "", err
+			}
+		case multiKeys:
+			nkeys, err := dec.Array()
+			if err != nil {
+				return "", err
+			}
+			for j := 0; j < nkeys; j++ {
+				t, err := dec.Tag()
+				if err != nil {
+					return "", err
+				}
+				if t != v1Tags.hdkey {
+					return "", fmt.Errorf("%s: unsupported key tag %d", name, t)
+				}
+				arg, err := decodeHDKey(dec, keys)
+				if err != nil {
+					return "", err
+				}
+				args = append(args, arg)
+			}
+		default:
+			if err := dec.Skip(); err != nil {
+				return "", err
+			}
+		}
+	}
+	if len(args) == 0 {
+		return "", fmt.Errorf("%s: missing keys", name)
+	}
+	return fmt.Sprintf("%s(%d,%s)", name, threshold, strings.Join(args, ",")), nil
+}
+
+// decodeHDKey decodes a crypto-hdkey following its tag, appends it
+// to keys and returns its key argument.
+func decodeHDKey(dec *cbor.Decoder, keys *[]psbt.ExtendedKey) (string, error) {
+	k, children, hasChildren, err := readHDKey(dec, v1Tags)
+	if err != nil {
+		return "", err
+	}
+	arg := "@" + strconv.Itoa(len(*keys))
+	*keys = append(*keys, k)
+	if !hasChildren {
+		return arg, nil
+	}
+	for _, c := range children {
+		arg += "/"
+		if c.wildcard {
+			arg += "*"
+		} else {
+			arg += strconv.FormatUint(uint64(c.index), 10)
+		}
+		if c.hardened {
+			arg += "'"
+		}
+	}
+	return arg, nil
+}
@@ -0,0 +1,95 @@
+package cod
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// cryptoOutputVector is test vector 3 of BCR-2020-010, a 1-of-2
+// multisig of the master key and m/0 of BIP-32 test vector 2.
+const cryptoOutputVector = "d90191d90196a201010282d9012fa403582103cbcaa9c98c877a26977d00825c956a238e8dddfbd322cce4f74b0b5bd6ace4a704582060499f801b896d83179a4374aeb7822aaeaceaa0db1f85ee3e904c4defbd968906d90130a1018007d90130a1018601f400f480f4d9012fa403582102fc9e5af0ac8d9b3cecfe2a888e2117ba3d089d8585886c9c826b6b22a98d12ea045820f0909affaa7ee7abe5dd4e100598d4dc53cd709d5a5c2cac40e7412f232f7c9c06d90130a2018200f4021abd16bee507d90130a1018600f400f480f4"
+
+func TestDecodeCryptoOutputVector(t *testing.T) {
+	data, err := hex.DecodeString(cryptoOutputVector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := DecodeCryptoOutput(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wantDesc = "wsh(multi(1,@0/1/0/*,@1/0/0/*))"
+	if d.Descriptor != wantDesc {
+		t.Errorf("descriptor %s, want %s", d.Descriptor, wantDesc)
+	}
+	wantKeys := []string{
+		"[00000000]xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB",
+		"[bd16bee5/0]xpub69H7F5d8KSRgmmdJg2KhpAK8SR3DjMwAdkxj3ZuxV27CprR9LgpeyGmXUbC6wb7ERfvrnKZjXoUmmDznezpbZb7ap6r1D3tgFxHmwMkQTPH",
+	}
+	if len(d.Keys) != len(wantKeys) {
+		t.Fatalf("%d keys, want %d", len(d.Keys), len(wantKeys))
+	}
+	for i, k := range d.Keys {
+		if got := k.OriginString(); got != wantKeys[i] {
+			t.Errorf("key %d: %s, want %s", i, got, wantKeys[i])
+		}
+	}
+	// Re-encoding adds the parent fingerprints, but preserves
+	// the descriptor.
+	enc, err := EncodeCryptoOutput(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := DecodeCryptoOutput(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d, d2) {
+		t.Errorf("round trip mismatch:\n%+v\n%+v", d, d2)
+	}
+}
+
+func TestCryptoOutputMultipath(t *testing.T) {
+	k := testKey(t)
+	for _, tmpl := range []string{"wsh(sortedmulti(1,@0/<0;1>/*))", "wsh(sortedmulti(1,@0/**))"} {
+		d := OutputDescriptor{Descriptor: tmpl, Keys: []psbt.ExtendedKey{k}}
+		if _, err := EncodeCryptoOutput(d); err == nil {
+			t.Errorf("%s: EncodeCryptoOutput accepted a multipath descriptor", tmpl)
+		}
+		outputs, err := EncodeCryptoOutputs(d)
+		if err != nil {
+			t.Fatalf("%s: %v", tmpl, err)
+		}
+		want := []string{"wsh(sortedmulti(1,@0/0/*))", "wsh(sortedmulti(1,@0/1/*))"}
+		if len(outputs) != len(want) {
+			t.Fatalf("%s: %d outputs, want %d", tmpl, len(outputs), len(want))
+		}
+		for i, out := range outputs {
+			dec, err := DecodeCryptoOutput(out)
+			if err != nil {
+				t.Fatalf("%s: output %d: %v", tmpl, i, err)
+			}
+			if dec.Descriptor != want[i] {
+				t.Errorf("%s: output %d: %s, want %s", tmpl, i, dec.Descriptor, want[i])
+			}
+		}
+	}
+}
+
+func TestCryptoOutputBareKey(t *testing.T) {
+	d := OutputDescriptor{Descriptor: "wpkh(@0)", Keys: []psbt.ExtendedKey{testKey(t)}}
+	enc, err := EncodeCryptoOutput(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := DecodeCryptoOutput(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.Descriptor != d.Descriptor {
+		t.Errorf("decoded %s, want %s", dec.Descriptor, d.Descriptor)
+	}
+}
@@ -0,0 +1,436 @@
+package cod
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// This file implements operations on the descriptor template, where
+// keys are referenced by @-prefixed indices into OutputDescriptor.Keys.
+
+// Expand returns the descriptor with every key placeholder replaced
+// by the corresponding key in origin form. The /** shorthand is
+// expanded to /<0;1>/* and any checksum is removed.
+func (d OutputDescriptor) Expand() (string, error) {
+	tmpl, _, _ := strings.Cut(d.Descriptor, "#")
+	var b strings.Builder
+	for i := 0; i < len(tmpl); {
+		if tmpl[i] != '@' {
+			b.WriteByte(tmpl[i])
+			i++
+			continue
+		}
+		idx, end, err := parsePlaceholder(tmpl, i)
+		if err != nil {
+			return "", fmt.Errorf("serdesc: %w", err)
+		}
+		if idx >= len(d.Keys) {
+			return "", fmt.Errorf("serdesc: key placeholder @%d out of range", idx)
+		}
+		b.WriteString(d.Keys[idx].OriginString())
+		if rest := tmpl[end:]; strings.HasPrefix(rest, "/**") {
+			b.WriteString("/<0;1>/*")
+			end += len("/**")
+		}
+		i = end
+	}
+	return b.String(), nil
+}
+
+// NormalizeDescriptor returns a canonical form of the descriptor s, for
+// comparing descriptors from different sources. Surrounding whitespace
+// and the checksum are removed after verifying the checksum, hardened
+// markers h and H are replaced by ', key origin fingerprints are lower
+// cased and the /** shorthand is expanded to /<0;1>/*. The keys of
+// sortedmulti are not reordered, because their order is part of the
+// descriptor string even though it doesn't affect the script.
+func NormalizeDescriptor(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if err := VerifyChecksum(s); err != nil {
+		return "", fmt.Errorf("serdesc: %w", err)
+	}
+	s, _, _ = strings.Cut(s, "#")
+	if DescriptorChecksum(s) == "" {
+		return "", errors.New("serdesc: invalid character in descriptor")
+	}
+	s = strings.ReplaceAll(s, "/**", "/<0;1>/*")
+	b := []byte(s)
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '[':
+			// Lower case the fingerprint of the key origin.
+			for j := i + 1; j < len(b) && b[j] != '/' && b[j] != ']'; j++ {
+				if 'A' <= b[j] && b[j] <= 'F' {
+					b[j] += 'a' - 'A'
+				}
+			}
+		case '/', '<', ';':
+			// Normalize the hardened marker of a derivation index.
+			j := i + 1
+			for j < len(b) && '0' <= b[j] && b[j] <= '9' {
+				j++
+			}
+			if j > i+1 && j < len(b) && (b[j] == 'h' || b[j] == 'H') {
+				b[j] = '\''
+			}
+		}
+	}
+	return string(b), nil
+}
+
+// ErrKeyCount is returned by Validate when the number of keys
+// doesn't match the keys referenced by the descriptor.
+var ErrKeyCount = errors.New("key count mismatch")
+
+// Validate checks that the descriptor references every key
+// and no others. Keys are counted by their distinct placeholder
+// indices; derivation suffixes such as /<0;1>/* and /** belong to
+// the preceding placeholder and don't add keys.
+func (d OutputDescriptor) Validate() error {
+	indices, err := placeholders(d.Descriptor)
+	if err != nil {
+		return fmt.Errorf("serdesc: %w", err)
+	}
+	referenced := make(map[int]bool)
+	want := 0
+	for _, idx := range indices {
+		// Compare before adding one, which overflows
+		// for the largest placeholder indices.
+		if idx >= len(d.Keys) {
+			return fmt.Errorf("serdesc: %w: descriptor references key @%d, found %d keys", ErrKeyCount, idx, len(d.Keys))
+		}
+		referenced[idx] = true
+		want = max(want, idx+1)
+	}
+	if want != len(d.Keys) {
+		return fmt.Errorf("serdesc: %w: descriptor references %d keys, found %d", ErrKeyCount, want, len(d.Keys))
+	}
+	for i := range d.Keys {
+		if !referenced[i] {
+			return fmt.Errorf("serdesc: key @%d not referenced by descriptor", i)
+		}
+	}
+	return nil
+}
+
+// SplitMultipath returns a descriptor template for each path of the
+// BIP-389 multipath groups in the descriptor, such as one for the
+// receive chain and one for the change chain of @0/<0;1>/*. The /**
+// shorthand is treated as /<0;1>/*, and any checksum is removed. A
+// descriptor without multipath groups is returned as is.
+func (d OutputDescriptor) SplitMultipath() ([]string, error) {
+	tmpl, _, _ := strings.Cut(d.Descriptor, "#")
+	tmpl = strings.ReplaceAll(tmpl, "/**", "/<0;1>/*")
+	var parts []string
+	var groups [][]string
+	for {
+		start := strings.IndexByte(tmpl, '<')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(tmpl[start:], '>')
+		if end == -1 {
+			return nil, errors.New("serdesc: unterminated multipath group")
+		}
+		end += start
+		g := strings.Split(tmpl[start+1:end], ";")
+		if len(groups) > 0 && len(g) != len(groups[0]) {
+			return nil, fmt.Errorf("serdesc: multipath groups of different lengths %d and %d", len(groups[0]), len(g))
+		}
+		parts = append(parts, tmpl[:start])
+		groups = append(groups, g)
+		tmpl = tmpl[end+1:]
+	}
+	if len(groups) == 0 {
+		return []string{tmpl}, nil
+	}
+	descs := make([]string, len(groups[0]))
+	for i := range descs {
+		var b strings.Builder
+		for j, g := range groups {
+			b.WriteString(parts[j])
+			b.WriteString(g[i])
+		}
+		b.WriteString(tmpl)
+		descs[i] = b.String()
+	}
+	return descs, nil
+}
+
+// ScriptType returns the output script type of the descriptor,
+// determined by its outer functions. The functions must nest as in
+// the standard single key and multisig descriptors: sh may contain
+// multi, sortedmulti, wpkh or wsh, and wsh may contain multi or
+// sortedmulti. Other combinations, such as sh(tr(...)) or
+// wsh(wpkh(...)), result in an error.
+func (d OutputDescriptor) ScriptType() (psbt.ScriptType, error) {
+	tmpl, _, _ := strings.Cut(d.Descriptor, "#")
+	e, err := parseExpr(tmpl)
+	if err != nil {
+		return 0, fmt.Errorf("serdesc: %w", err)
+	}
+	var inner expr
+	if len(e.args) == 1 {
+		inner = e.args[0]
+	}
+	switch {
+	case e.name == "pkh":
+		return psbt.P2PKH, nil
+	case e.name == "wpkh":
+		return psbt.P2WPKH, nil
+	case e.name == "tr":
+		return psbt.P2TR, nil
+	case e.name == "wsh" && isMulti(inner):
+		return psbt.P2WSH, nil
+	case e.name == "sh" && inner.name == "wpkh":
+		return psbt.P2SHP2WPKH, nil
+	case e.name == "sh" && inner.name == "wsh" && len(inner.args) == 1 && isMulti(inner.args[0]):
+		return psbt.P2SHP2WSH, nil
+	case e.name == "sh" && isMulti(inner):
+		return psbt.P2SH, nil
+	case e.name == "sh" || e.name == "wsh":
+		return 0, fmt.Errorf("serdesc: unsupported script function %q in %s", inner.name, e.name)
+	default:
+		return 0, fmt.Errorf("serdesc: unsupported script function %q", e.name)
+	}
+}
+
+// isMulti reports whether e is a multi or sortedmulti expression.
+func isMulti(e expr) bool {
+	return e.name == "multi" || e.name == "sortedmulti"
+}
+
+// SLIP132Keys returns the descriptor keys with the SLIP-132 version
+// bytes matching the script type of the descriptor, such as Zpub keys
+// for a wsh multisig, for export to wallets that expect them.
+func (d OutputDescriptor) SLIP132Keys() ([]psbt.ExtendedKey, error) {
+	t, err := d.ScriptType()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]psbt.ExtendedKey, len(d.Keys))
+	for i, k := range d.Keys {
+		keys[i], err = k.WithScriptType(t)
+		if err != nil {
+			return nil, fmt.Errorf("serdesc: key %d: %w", i, err)
+		}
+	}
+	return keys, nil
+}
+
+// Cosigner describes a descriptor key for display.
+type Cosigner struct {
+	MasterFingerprint uint32
+	// Path is the derivation path from the master key.
+	Path []uint32
+	// Xpub is the key encoded with the SLIP-132 version bytes
+	// matching the descriptor script type, such as "Zpub...".
+	Xpub string
+	// Label is the label of the key, if any.
+	Label string
+}
+
+// Cosigners returns a Cosigner for each key of the descriptor,
+// in order.
+func (d OutputDescriptor) Cosigners() ([]Cosigner, error) {
+	keys, err := d.SLIP132Keys()
+	if err != nil {
+		return nil, err
+	}
+	cosigners := make([]Cosigner, len(keys))
+	for i, k := range keys {
+		cosigners[i] = Cosigner{
+			MasterFingerprint: k.MasterFingerprint,
+			Path:              k.Path,
+			Xpub:              k.String(),
+			Label:             d.keyLabel(i),
+		}
+	}
+	return cosigners, nil
+}
+
+// UniqueKeys returns the descriptor keys with duplicates removed,
+// in order of first appearance.
+func (d OutputDescriptor) UniqueKeys() []psbt.ExtendedKey {
+	var keys []psbt.ExtendedKey
+	for _, k := range d.Keys {
+		if !slices.ContainsFunc(keys, k.Equal) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// KeysFromDescriptor returns the keys of the descriptor. If the
+// template embeds keys in origin form, such as
+// "[dc567276/48'/0'/0'/2']xpub...", they are parsed and returned in
+// order of first appearance; otherwise Keys is returned. A descriptor
+// with both embedded keys and Keys is ambiguous and an error.
+func (d OutputDescriptor) KeysFromDescriptor() ([]psbt.ExtendedKey, error) {
+	tmpl, _, _ := strings.Cut(d.Descriptor, "#")
+	embedded, err := templateFromKeys(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("serdesc: %w", err)
+	}
+	if len(embedded.Keys) == 0 {
+		return d.Keys, nil
+	}
+	if len(d.Keys) > 0 {
+		return nil, errors.New("serdesc: descriptor has both embedded keys and key maps")
+	}
+	return embedded.Keys, nil
+}
+
+// ErrMixedNetworks is returned when the keys of a descriptor
+// belong to different networks.
+var ErrMixedNetworks = errors.New("keys from different networks")
+
+// Network returns the network of the descriptor keys, and an error
+// if the keys disagree or have unknown version bytes.
+func (d OutputDescriptor) Network() (psbt.Network, error) {
+	var net psbt.Network
+	for i, k := range d.Keys {
+		n, err := k.Network()
+		if err != nil {
+			return 0, fmt.Errorf("serdesc: key %d: %w", i, err)
+		}
+		if i > 0 && n != net {
+			return 0, fmt.Errorf("serdesc: %w: key %d is %s, key 0 is %s", ErrMixedNetworks, i, n, net)
+		}
+		net = n
+	}
+	return net, nil
+}
+
+// SameWallet reports whether a and b describe the same wallet, such
+// as exports of one wallet by different tools. The descriptors must
+// have the same script type and network, and equal templates in the
+// form returned by NormalizeDescriptor, which includes the multisig
+// threshold. Keys are compared by their serialization without the
+// version bytes, so an xpub matches the zpub of the same key, and
+// their order in Keys doesn't matter. The order of sortedmulti keys
+// is ignored as well. Names, labels and key origins are not compared.
+func SameWallet(a, b OutputDescriptor) (bool, error) {
+	for _, d := range []OutputDescriptor{a, b} {
+		if err := d.Validate(); err != nil {
+			return false, err
+		}
+	}
+	ta, err := a.ScriptType()
+	if err != nil {
+		return false, err
+	}
+	tb, err := b.ScriptType()
+	if err != nil {
+		return false, err
+	}
+	na, err := a.Network()
+	if err != nil {
+		return false, err
+	}
+	nb, err := b.Network()
+	if err != nil {
+		return false, err
+	}
+	if ta != tb || na != nb {
+		return false, nil
+	}
+	wa, err := a.walletString()
+	if err != nil {
+		return false, err
+	}
+	wb, err := b.walletString()
+	if err != nil {
+		return false, err
+	}
+	return wa == wb, nil
+}
+
+// walletString returns the normalized template of d with placeholders
+// replaced by their hex encoded key without the version bytes, and
+// with the keys of sortedmulti in sorted order.
+func (d OutputDescriptor) walletString() (string, error) {
+	tmpl, err := NormalizeDescriptor(d.Descriptor)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i := 0; i < len(tmpl); {
+		if tmpl[i] != '@' {
+			b.WriteByte(tmpl[i])
+			i++
+			continue
+		}
+		idx, end, err := parsePlaceholder(tmpl, i)
+		if err != nil {
+			return "", fmt.Errorf("serdesc: %w", err)
+		}
+		if idx >= len(d.Keys) {
+			return "", fmt.Errorf("serdesc: key placeholder @%d out of range", idx)
+		}
+		k := d.Keys[idx]
+		if len(k.PubKey()) == 0 {
+			return "", fmt.Errorf("serdesc: key @%d: invalid extended key of %d bytes", idx, len(k.Key))
+		}
+		fmt.Fprintf(&b, "%x", k.Key[4:])
+		i = end
+	}
+	e, err := parseExpr(b.String())
+	if err != nil {
+		return "", fmt.Errorf("serdesc: %w", err)
+	}
+	return formatWalletExpr(e), nil
+}
+
+// formatWalletExpr formats e with the keys of sortedmulti sorted.
+func formatWalletExpr(e expr) string {
+	if e.name == "" {
+		return e.text
+	}
+	args := make([]string, len(e.args))
+	for i, arg := range e.args {
+		args[i] = formatWalletExpr(arg)
+	}
+	if e.name == "sortedmulti" && len(args) > 1 {
+		slices.Sort(args[1:])
+	}
+	return e.name + "(" + strings.Join(args, ",") + ")"
+}
+
+// placeholders returns the indices of the key placeholders
+// in a descriptor template, in order of appearance.
+func placeholders(tmpl string) ([]int, error) {
+	tmpl, _, _ = strings.Cut(tmpl, "#")
+	var indices []int
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '@' {
+			continue
+		}
+		idx, end, err := parsePlaceholder(tmpl, i)
+		if err != nil {
+			return nil, err
+		}
+		indices = append(indices, idx)
+		i = end - 1
+	}
+	return indices, nil
+}
+
+// parsePlaceholder parses the key placeholder starting with '@'
+// at tmpl[start] and returns its index and end position.
+func parsePlaceholder(tmpl string, start int) (int, int, error) {
+	end := start + 1
+	for end < len(tmpl) && '0' <= tmpl[end] && tmpl[end] <= '9' {
+		end++
+	}
+	idx, err := strconv.Atoi(tmpl[start+1 : end])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid key placeholder at offset %d", start)
+	}
+	return idx, end, nil
+}
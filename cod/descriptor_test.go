@@ -0,0 +1,136 @@
+package cod
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+func TestSplitMultipath(t *testing.T) {
+	tests := []struct {
+		desc string
+		want []string
+	}{
+		{
+			"wsh(sortedmulti(2,@0/<0;1>/*,@1/<0;1>/*))",
+			[]string{"wsh(sortedmulti(2,@0/0/*,@1/0/*))", "wsh(sortedmulti(2,@0/1/*,@1/1/*))"},
+		},
+		{
+			"wsh(sortedmulti(2,@0/**,@1/**))#00000000",
+			[]string{"wsh(sortedmulti(2,@0/0/*,@1/0/*))", "wsh(sortedmulti(2,@0/1/*,@1/1/*))"},
+		},
+		{
+			"tr(@0/<0;1;2>/*)",
+			[]string{"tr(@0/0/*)", "tr(@0/1/*)", "tr(@0/2/*)"},
+		},
+		{
+			"wpkh(@0/0/*)",
+			[]string{"wpkh(@0/0/*)"},
+		},
+	}
+	for _, test := range tests {
+		got, err := OutputDescriptor{Descriptor: test.desc}.SplitMultipath()
+		if err != nil || !slices.Equal(got, test.want) {
+			t.Errorf("SplitMultipath(%s) = %q, %v, want %q", test.desc, got, err, test.want)
+		}
+	}
+	for _, desc := range []string{"wsh(multi(1,@0/<0;1>/*,@1/<0;1;2>/*))", "wpkh(@0/<0;1/*)"} {
+		if got, err := (OutputDescriptor{Descriptor: desc}).SplitMultipath(); err == nil {
+			t.Errorf("SplitMultipath(%s) = %q, want error", desc, got)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	k := testKey(t)
+	for _, desc := range []string{"wpkh(@0/<0;1>/*)", "wpkh(@0/**)#00000000"} {
+		got, err := OutputDescriptor{Descriptor: desc, Keys: []psbt.ExtendedKey{k}}.Expand()
+		if want := "wpkh(" + testXpub + "/<0;1>/*)"; err != nil || got != want {
+			t.Errorf("Expand(%s) = %s, %v, want %s", desc, got, err, want)
+		}
+	}
+	if _, err := (OutputDescriptor{Descriptor: "wpkh(@1/**)", Keys: []psbt.ExtendedKey{k}}).Expand(); err == nil {
+		t.Error("Expand accepted an out of range placeholder")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	k := testKey(t)
+	tests := []struct {
+		desc  string
+		keys  int
+		valid bool
+	}{
+		{"wsh(sortedmulti(2,@0/<0;1>/*,@1/<0;1>/*))", 2, true},
+		{"wsh(sortedmulti(2,@0/**,@1/**))", 2, true},
+		{"wsh(sortedmulti(2,@0/<0;1>/*,@1/<0;1>/*))", 3, false},
+		{"wsh(sortedmulti(2,@0/<0;1>/*,@1/<0;1>/*))", 1, false},
+		{"wsh(sortedmulti(2,@0/<0;1>/*,@2/<0;1>/*))", 3, false},
+		{"wsh(or_d(pk(@0/<0;1>/*),and_v(v:pk(@1/<0;1>/*),older(144))))", 2, true},
+		{"wsh(multi(1,@9223372036854775807))", 0, false},
+		{"wsh(multi(1,@0,@9223372036854775807))", 1, false},
+	}
+	for _, test := range tests {
+		d := OutputDescriptor{Descriptor: test.desc}
+		for i := 0; i < test.keys; i++ {
+			d.Keys = append(d.Keys, k)
+		}
+		err := d.Validate()
+		if test.valid && err != nil {
+			t.Errorf("Validate(%s) with %d keys: %v", test.desc, test.keys, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Validate(%s) with %d keys succeeded", test.desc, test.keys)
+		}
+	}
+	d := OutputDescriptor{Descriptor: "wpkh(@0/**)", Keys: []psbt.ExtendedKey{k, k}}
+	if err := d.Validate(); !errors.Is(err, ErrKeyCount) {
+		t.Errorf("Validate with an extra key returned %v, want %v", err, ErrKeyCount)
+	}
+}
+
+func TestScriptType(t *testing.T) {
+	tests := []struct {
+		desc string
+		want psbt.ScriptType
+	}{
+		{"pkh(@0/**)", psbt.P2PKH},
+		{"wpkh(@0/**)", psbt.P2WPKH},
+		{"tr(@0/**)", psbt.P2TR},
+		{"sh(wpkh(@0/**))", psbt.P2SHP2WPKH},
+		{"sh(multi(1,@0/**,@1/**))", psbt.P2SH},
+		{"sh(sortedmulti(1,@0/**,@1/**))", psbt.P2SH},
+		{"wsh(multi(1,@0/**,@1/**))", psbt.P2WSH},
+		{"wsh(sortedmulti(1,@0/**,@1/**))#00000000", psbt.P2WSH},
+		{"sh(wsh(sortedmulti(1,@0/**,@1/**)))", psbt.P2SHP2WSH},
+	}
+	for _, test := range tests {
+		got, err := OutputDescriptor{Descriptor: test.desc}.ScriptType()
+		if err != nil || got != test.want {
+			t.Errorf("ScriptType(%s) = %s, %v, want %s", test.desc, got, err, test.want)
+		}
+	}
+	for _, desc := range []string{
+		"sh(tr(@0/**))",
+		"sh(pkh(@0/**))",
+		"sh(sh(wpkh(@0/**)))",
+		"wsh(wpkh(@0/**))",
+		"wsh(wsh(multi(1,@0/**)))",
+		"sh(wsh(wpkh(@0/**)))",
+		"sh(wsh(tr(@0/**)))",
+		"raw(deadbeef)",
+	} {
+		if got, err := (OutputDescriptor{Descriptor: desc}).ScriptType(); err == nil {
+			t.Errorf("ScriptType(%s) = %s, want error", desc, got)
+		}
+	}
+}
+
+func TestSameWalletMissingKey(t *testing.T) {
+	d := OutputDescriptor{Descriptor: "wsh(multi(1,@9223372036854775807))"}
+	if _, err := SameWallet(d, d); err == nil {
+		t.Error("SameWallet succeeded for a placeholder without a key")
+	}
+}
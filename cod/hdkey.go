@@ -0,0 +1,344 @@
+package cod
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/seedhammer/bip-serialized-descriptors/internal/cbor"
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// This file implements the hdkey CBOR type of BCR-2020-007, shared by
+// the crypto-output and output-descriptor representations.
+
+// hdkeyTags are the CBOR tags of hdkey and its nested types, which
+// differ between the original and the current tag registry.
+type hdkeyTags struct {
+	hdkey, keypath, coinInfo uint64
+}
+
+var (
+	// v1Tags are the tags of crypto-hdkey, used by crypto-output.
+	v1Tags = hdkeyTags{hdkey: 303, keypath: 304, coinInfo: 305}
+	// v2Tags are the tags of hdkey, used by output-descriptor.
+	v2Tags = hdkeyTags{hdkey: 40303, keypath: 40304, coinInfo: 40305}
+)
+
+// Map keys of hdkey, keypath and coin-info.
+const (
+	hdkeyIsMaster   = 1
+	hdkeyIsPrivate  = 2
+	hdkeyKeyData    = 3
+	hdkeyChainCode  = 4
+	hdkeyUseInfo    = 5
+	hdkeyOrigin     = 6
+	hdkeyChildren   = 7
+	hdkeyParentFP   = 8
+	keypathComps    = 1
+	keypathSourceFP = 2
+	keypathDepth    = 3
+	coinInfoType    = 1
+	coinInfoNetwork = 2
+)
+
+// coinInfoTestnet is the coin-info network of Bitcoin testnet.
+const coinInfoTestnet = 1
+
+// Extended public key version bytes for hdkey networks.
+const (
+	versionMainnet = 0x0488b21e
+	versionTestnet = 0x043587cf
+)
+
+// pathComponent is an element of a keypath.
+type pathComponent struct {
+	index    uint32
+	wildcard bool
+	hardened bool
+}
+
+// writeHDKey encodes k as a tagged hdkey, including children
+// if hasChildren is set.
+func writeHDKey(enc *cbor.Encoder, tags hdkeyTags, k psbt.ExtendedKey, children []pathComponent, hasChildren bool) error {
+	if k.PubKey() == nil {
+		return fmt.Errorf("invalid extended key length %d", len(k.Key))
+	}
+	net, err := k.Network()
+	if err != nil {
+		return err
+	}
+	n := 4
+	if net != psbt.Mainnet {
+		n++
+	}
+	if hasChildren {
+		n++
+	}
+	enc.Tag(tags.hdkey)
+	enc.Map(n)
+	enc.Uint(hdkeyKeyData)
+	enc.ByteString(k.PubKey())
+	enc.Uint(hdkeyChainCode)
+	enc.ByteString(k.ChainCode())
+	if net != psbt.Mainnet {
+		enc.Uint(hdkeyUseInfo)
+		enc.Tag(tags.coinInfo)
+		enc.Map(1)
+		enc.Uint(coinInfoNetwork)
+		enc.Uint(coinInfoTestnet)
+	}
+	var origin []pathComponent
+	for _, p := range k.Path {
+		origin = append(origin, pathComponent{
+			index:    p &^ psbt.HardenedKeyStart,
+			hardened: p >= psbt.HardenedKeyStart,
+		})
+	}
+	enc.Uint(hdkeyOrigin)
+	encodeKeypath(enc, tags, origin, k.MasterFingerprint, k.Depth())
+	if hasChildren {
+		enc.Uint(hdkeyChildren)
+		encodeKeypath(enc, tags, children, 0, 0)
+	}
+	enc.Uint(hdkeyParentFP)
+	enc.Uint(uint64(k.ParentFingerprint()))
+	return nil
+}
+
+// encodeKeypath encodes a keypath. A zero fingerprint and
+// depth are omitted, as they are for key children.
+func encodeKeypath(enc *cbor.Encoder, tags hdkeyTags, comps []pathComponent, fp uint32, depth uint8) {
+	n := 1
+	if fp != 0 {
+		n++
+	}
+	if depth != 0 {
+		n++
+	}
+	enc.Tag(tags.keypath)
+	enc.Map(n)
+	enc.Uint(keypathComps)
+	enc.Array(2 * len(comps))
+	for _, c := range comps {
+		if c.wildcard {
+			enc.Array(0)
+		} else {
+			enc.Uint(uint64(c.index))
+		}
+		enc.Bool(c.hardened)
+	}
+	if fp != 0 {
+		enc.Uint(keypathSourceFP)
+		enc.Uint(uint64(fp))
+	}
+	if depth != 0 {
+		enc.Uint(keypathDepth)
+		enc.Uint(uint64(depth))
+	}
+}
+
+// readHDKey decodes an hdkey following its tag and returns the key
+// along with its children, if any.
+func readHDKey(dec *cbor.Decoder, tags hdkeyTags) (psbt.ExtendedKey, []pathComponent, bool, error) {
+	n, err := dec.Map()
+	if err != nil {
+		return psbt.ExtendedKey{}, nil, false, err
+	}
+	var (
+		keyData, chainCode []byte
+		version            uint32 = versionMainnet
+		origin, children   []pathComponent
+		hasChildren        bool
+		fp, parentFP       uint32
+		hasParentFP        bool
+		depth              = -1
+	)
+	for i := 0; i < n; i++ {
+		k, err := dec.Uint()
+		if err != nil {
+			return psbt.ExtendedKey{}, nil, false, err
+		}
+		switch k {
+		case hdkeyIsMaster, hdkeyIsPrivate:
+			v, err := dec.Bool()
+			if err != nil {
+				return psbt.ExtendedKey{}, nil, false, err
+			}
+			if v {
+				return psbt.ExtendedKey{}, nil, false, errors.New("master and private keys are not supported")
+			}
+		case hdkeyKeyData:
+			keyData, err = dec.ByteString()
+		case hdkeyChainCode:
+			chainCode, err = dec.ByteString()
+		case hdkeyUseInfo:
+			version, err = decodeCoinInfo(dec, tags)
+		case hdkeyOrigin:
+			origin, fp, depth, err = decodeKeypath(dec, tags)
+		case hdkeyChildren:
+			children, _, _, err = decodeKeypath(dec, tags)
+			hasChildren = true
+		case hdkeyParentFP:
+			var v uint64
+			v, err = dec.Uint()
+			if v > 0xffffffff {
+				err = errors.New("invalid parent fingerprint")
+			}
+			parentFP, hasParentFP = uint32(v), true
+		default:
+			err = dec.Skip()
+		}
+		if err != nil {
+			return psbt.ExtendedKey{}, nil, false, err
+		}
+	}
+	if len(keyData) != 33 || len(chainCode) != 32 {
+		return psbt.ExtendedKey{}, nil, false, errors.New("hdkey: missing or invalid key data or chain code")
+	}
+	if depth == -1 {
+		depth = len(origin)
+	}
+	if depth > 0xff {
+		return psbt.ExtendedKey{}, nil, false, errors.New("hdkey: invalid depth")
+	}
+	// The parent fingerprint is optional, but the parent of a key at
+	// depth 1 is the master key.
+	if !hasParentFP && depth == 1 {
+		parentFP = fp
+	}
+	var path []uint32
+	for _, c := range origin {
+		if c.wildcard {
+			return psbt.ExtendedKey{}, nil, false, errors.New("hdkey: wildcard in origin")
+		}
+		p := c.index
+		if c.hardened {
+			p += psbt.HardenedKeyStart
+		}
+		path = append(path, p)
+	}
+	var childNum uint32
+	if len(path) > 0 {
+		childNum = path[len(path)-1]
+	}
+	xpub := binary.BigEndian.AppendUint32(nil, version)
+	xpub = append(xpub, byte(depth))
+	xpub = binary.BigEndian.AppendUint32(xpub, parentFP)
+	xpub = binary.BigEndian.AppendUint32(xpub, childNum)
+	xpub = append(xpub, chainCode...)
+	xpub = append(xpub, keyData...)
+	return psbt.ExtendedKey{
+		MasterFingerprint: fp,
+		Path:              path,
+		Key:               xpub,
+	}, children, hasChildren, nil
+}
+
+// decodeCoinInfo decodes a tagged coin-info and returns the
+// extended key version bytes of its network.
+func decodeCoinInfo(dec *cbor.Decoder, tags hdkeyTags) (uint32, error) {
+	if t, err := dec.Tag(); err != nil || t != tags.coinInfo {
+		return 0, errors.New("invalid coin info")
+	}
+	n, err := dec.Map()
+	if err != nil {
+		return 0, err
+	}
+	version := uint32(versionMainnet)
+	for i := 0; i < n; i++ {
+		k, err := dec.Uint()
+		if err != nil {
+			return 0, err
+		}
+		if k != coinInfoType && k != coinInfoNetwork {
+			if err := dec.Skip(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		v, err := dec.Uint()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case k == coinInfoType && v != 0:
+			return 0, fmt.Errorf("unsupported coin type %d", v)
+		case k == coinInfoNetwork && v == coinInfoTestnet:
+			version = versionTestnet
+		case k == coinInfoNetwork && v != 0:
+			return 0, fmt.Errorf("unknown network %d", v)
+		}
+	}
+	return version, nil
+}
+
+// decodeKeypath decodes a tagged keypath. The depth is -1
+// if not present.
+func decodeKeypath(dec *cbor.Decoder, tags hdkeyTags) ([]pathComponent, uint32, int, error) {
+	if t, err := dec.Tag(); err != nil || t != tags.keypath {
+		return nil, 0, 0, errors.New("invalid keypath")
+	}
+	n, err := dec.Map()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var comps []pathComponent
+	var fp uint32
+	depth := -1
+	for i := 0; i < n; i++ {
+		k, err := dec.Uint()
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		switch k {
+		case keypathComps:
+			l, err := dec.Array()
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			if l%2 != 0 {
+				return nil, 0, 0, errors.New("invalid keypath components")
+			}
+			for j := 0; j < l; j += 2 {
+				var c pathComponent
+				if m, _ := dec.Peek(); m == cbor.MajorArray {
+					if l, err := dec.Array(); err != nil || l != 0 {
+						return nil, 0, 0, errors.New("unsupported keypath component")
+					}
+					c.wildcard = true
+				} else {
+					idx, err := dec.Uint()
+					if err != nil {
+						return nil, 0, 0, err
+					}
+					if idx >= psbt.HardenedKeyStart {
+						return nil, 0, 0, fmt.Errorf("keypath index %d out of range", idx)
+					}
+					c.index = uint32(idx)
+				}
+				if c.hardened, err = dec.Bool(); err != nil {
+					return nil, 0, 0, err
+				}
+				comps = append(comps, c)
+			}
+		case keypathSourceFP:
+			v, err := dec.Uint()
+			if err != nil || v > 0xffffffff {
+				return nil, 0, 0, errors.New("invalid source fingerprint")
+			}
+			fp = uint32(v)
+		case keypathDepth:
+			v, err := dec.Uint()
+			if err != nil || v > 0xff {
+				return nil, 0, 0, errors.New("invalid depth")
+			}
+			depth = int(v)
+		default:
+			if err := dec.Skip(); err != nil {
+				return nil, 0, 0, err
+			}
+		}
+	}
+	return comps, fp, depth, nil
+}
@@ -0,0 +1,126 @@
+package cod
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// jsonDescriptor is the JSON representation of an OutputDescriptor.
+type jsonDescriptor struct {
+	Name       string `json:"name,omitempty"`
+	Descriptor string `json:"descriptor"`
+	// Expanded is informational and ignored when unmarshaling.
+	Expanded    string      `json:"expanded,omitempty"`
+	Keys        []jsonKey   `json:"keys"`
+	FirstScript string      `json:"first_script,omitempty"`
+	Unknown     []jsonEntry `json:"unknown,omitempty"`
+}
+
+type jsonKey struct {
+	Fingerprint string `json:"fingerprint"`
+	Path        string `json:"path"`
+	Xpub        string `json:"xpub"`
+	Label       string `json:"label,omitempty"`
+}
+
+type jsonEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// MarshalJSON implements json.Marshaler. Keys are represented by their
+// fingerprint in hex, their path in m/48'/0' form and their base58
+// encoding. The expanded descriptor is included if the descriptor is
+// valid.
+func (d OutputDescriptor) MarshalJSON() ([]byte, error) {
+	jd := jsonDescriptor{
+		Name:        d.Name,
+		Descriptor:  d.Descriptor,
+		Keys:        []jsonKey{},
+		FirstScript: hex.EncodeToString(d.FirstScript),
+	}
+	if exp, err := d.Expand(); err == nil {
+		jd.Expanded = exp
+	}
+	for i, k := range d.Keys {
+		jd.Keys = append(jd.Keys, jsonKey{
+			Fingerprint: fmt.Sprintf("%.8x", k.MasterFingerprint),
+			Path:        psbt.FormatPath(k.Path),
+			Xpub:        k.String(),
+			Label:       d.keyLabel(i),
+		})
+	}
+	for _, e := range d.Unknown {
+		jd.Unknown = append(jd.Unknown, jsonEntry{
+			Key:   hex.EncodeToString(e.Key),
+			Value: hex.EncodeToString(e.Val),
+		})
+	}
+	// Don't escape the < and > of multipath derivations.
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(jd); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler for the representation
+// produced by MarshalJSON.
+func (d *OutputDescriptor) UnmarshalJSON(data []byte) error {
+	var jd jsonDescriptor
+	if err := json.Unmarshal(data, &jd); err != nil {
+		return err
+	}
+	desc := OutputDescriptor{
+		Name:       jd.Name,
+		Descriptor: jd.Descriptor,
+	}
+	var labels []string
+	for i, jk := range jd.Keys {
+		k, err := psbt.ParseExtendedKey(jk.Xpub)
+		if err != nil {
+			return fmt.Errorf("serdesc: key %d: %w", i, err)
+		}
+		if len(jk.Fingerprint) != 8 {
+			return fmt.Errorf("serdesc: key %d: invalid fingerprint %q", i, jk.Fingerprint)
+		}
+		mfp, err := strconv.ParseUint(jk.Fingerprint, 16, 32)
+		if err != nil {
+			return fmt.Errorf("serdesc: key %d: invalid fingerprint %q", i, jk.Fingerprint)
+		}
+		k.MasterFingerprint = uint32(mfp)
+		k.Path, err = psbt.ParsePath(jk.Path)
+		if err != nil {
+			return fmt.Errorf("serdesc: key %d: %w", i, err)
+		}
+		desc.Keys = append(desc.Keys, k)
+		labels = append(labels, jk.Label)
+	}
+	if hasLabels(labels) {
+		desc.KeyLabels = labels
+	}
+	if jd.FirstScript != "" {
+		script, err := hex.DecodeString(jd.FirstScript)
+		if err != nil {
+			return fmt.Errorf("serdesc: invalid first script: %w", err)
+		}
+		desc.FirstScript = script
+	}
+	for i, je := range jd.Unknown {
+		key, err1 := hex.DecodeString(je.Key)
+		val, err2 := hex.DecodeString(je.Value)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("serdesc: unknown entry %d: invalid hex", i)
+		}
+		desc.Unknown = append(desc.Unknown, psbt.Entry{Key: key, Val: val})
+	}
+	*d = desc
+	return nil
+}
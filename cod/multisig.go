@@ -0,0 +1,83 @@
+package cod
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNotMultisig is returned for descriptors that are not
+// multi or sortedmulti descriptors.
+var ErrNotMultisig = errors.New("not a multisig descriptor")
+
+// Multisig returns the threshold m and number of keys n of a multi or
+// sortedmulti descriptor, optionally wrapped in sh, wsh or sh(wsh), and
+// whether the keys are sorted. The number of keys must match Keys.
+func (d OutputDescriptor) Multisig() (m, n int, sorted bool, err error) {
+	ms, err := d.multisig()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return ms.threshold, len(ms.keys), ms.sorted, nil
+}
+
+// multisigInfo describes a multisig descriptor.
+type multisigInfo struct {
+	// wrappers is the script wrappers, such as "wsh" or "sh-wsh".
+	wrappers  string
+	threshold int
+	sorted    bool
+	// keys holds the key indices in the order of the descriptor.
+	keys []int
+}
+
+func (d OutputDescriptor) multisig() (multisigInfo, error) {
+	tmpl, _, _ := strings.Cut(d.Descriptor, "#")
+	e, err := parseExpr(tmpl)
+	if err != nil {
+		return multisigInfo{}, fmt.Errorf("serdesc: %w", err)
+	}
+	var wrappers []string
+	for len(e.args) == 1 && (e.name == "sh" || e.name == "wsh") {
+		wrappers = append(wrappers, e.name)
+		e = e.args[0]
+	}
+	ms := multisigInfo{
+		wrappers: strings.Join(wrappers, "-"),
+		sorted:   e.name == "sortedmulti",
+	}
+	switch ms.wrappers {
+	case "", "sh", "wsh", "sh-wsh":
+	default:
+		return multisigInfo{}, fmt.Errorf("serdesc: %w: unsupported wrapping %s", ErrNotMultisig, ms.wrappers)
+	}
+	if e.name != "multi" && e.name != "sortedmulti" {
+		return multisigInfo{}, fmt.Errorf("serdesc: %w", ErrNotMultisig)
+	}
+	if len(e.args) < 2 {
+		return multisigInfo{}, fmt.Errorf("serdesc: %s: missing keys", e.name)
+	}
+	ms.threshold, err = strconv.Atoi(e.args[0].text)
+	if err != nil {
+		return multisigInfo{}, fmt.Errorf("serdesc: %s: invalid threshold %q", e.name, e.args[0].text)
+	}
+	for _, arg := range e.args[1:] {
+		idx, _, err := parseKeyArg(arg.text)
+		if err != nil {
+			return multisigInfo{}, fmt.Errorf("serdesc: %s: %w", e.name, err)
+		}
+		if idx >= len(d.Keys) {
+			return multisigInfo{}, fmt.Errorf("serdesc: key placeholder @%d out of range", idx)
+		}
+		ms.keys = append(ms.keys, idx)
+	}
+	n := len(ms.keys)
+	if ms.threshold < 1 || ms.threshold > n {
+		return multisigInfo{}, fmt.Errorf("serdesc: %s: threshold %d out of range for %d keys", e.name, ms.threshold, n)
+	}
+	if n != len(d.Keys) {
+		return multisigInfo{}, fmt.Errorf("serdesc: %w: %s of %d keys, found %d", ErrKeyCount, e.name, n, len(d.Keys))
+	}
+	return ms, nil
+}
@@ -0,0 +1,119 @@
+package cod
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// This file implements a parser for the script expressions of
+// descriptor templates, sufficient for converting descriptors to
+// other representations.
+
+// expr is a parsed script expression. Function calls such as
+// wsh(...) have a name and arguments, while leaf arguments such as
+// thresholds and keys have only their text.
+type expr struct {
+	name string
+	args []expr
+	text string
+}
+
+// maxExprDepth limits the nesting of script expressions.
+const maxExprDepth = 16
+
+// parseExpr parses a descriptor template without checksum.
+func parseExpr(tmpl string) (expr, error) {
+	e, n, err := parseExprAt(tmpl, 0, 0)
+	if err != nil {
+		return expr{}, err
+	}
+	if n != len(tmpl) {
+		return expr{}, fmt.Errorf("unexpected %q at offset %d", tmpl[n], n)
+	}
+	return e, nil
+}
+
+// parseExprAt parses the expression starting at tmpl[start] and
+// returns it along with its end position.
+func parseExprAt(tmpl string, start, depth int) (expr, int, error) {
+	if depth > maxExprDepth {
+		return expr{}, 0, errors.New("expression nested too deeply")
+	}
+	i := start
+	for i < len(tmpl) && ('a' <= tmpl[i] && tmpl[i] <= 'z' || tmpl[i] == '_') {
+		i++
+	}
+	if i == start || i == len(tmpl) || tmpl[i] != '(' {
+		return parseLeaf(tmpl, start)
+	}
+	e := expr{name: tmpl[start:i]}
+	i++
+	for {
+		arg, end, err := parseExprAt(tmpl, i, depth+1)
+		if err != nil {
+			return expr{}, 0, err
+		}
+		e.args = append(e.args, arg)
+		if end == len(tmpl) {
+			return expr{}, 0, fmt.Errorf("missing ')' for %s", e.name)
+		}
+		i = end + 1
+		switch tmpl[end] {
+		case ')':
+			return e, i, nil
+		case ',':
+		default:
+			return expr{}, 0, fmt.Errorf("unexpected %q at offset %d", tmpl[end], end)
+		}
+	}
+}
+
+// parseLeaf parses a leaf argument that extends to the next ',' or ')'
+// outside braces.
+func parseLeaf(tmpl string, start int) (expr, int, error) {
+	depth := 0
+	i := start
+loop:
+	for ; i < len(tmpl); i++ {
+		switch tmpl[i] {
+		case '{', '(':
+			depth++
+		case '}':
+			depth--
+		case ')':
+			if depth == 0 {
+				break loop
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				break loop
+			}
+		}
+		if depth < 0 {
+			return expr{}, 0, fmt.Errorf("unbalanced %q at offset %d", tmpl[i], i)
+		}
+	}
+	if i == start {
+		return expr{}, 0, fmt.Errorf("missing argument at offset %d", start)
+	}
+	return expr{text: tmpl[start:i]}, i, nil
+}
+
+// parseKeyArg parses a key argument such as @0/<0;1>/* and returns
+// the key index and the derivation suffix.
+func parseKeyArg(arg string) (int, string, error) {
+	if !strings.HasPrefix(arg, "@") {
+		return 0, "", fmt.Errorf("unsupported key expression %q", arg)
+	}
+	idx, end, err := parsePlaceholder(arg, 0)
+	if err != nil {
+		return 0, "", err
+	}
+	suffix := arg[end:]
+	if suffix != "" && suffix[0] != '/' {
+		return 0, "", fmt.Errorf("invalid key expression %q", arg)
+	}
+	return idx, suffix, nil
+}
@@ -0,0 +1,58 @@
+package cod
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// DecodeReader decodes a serialized descriptor from r without
+// optional validation.
+func DecodeReader(r io.Reader) (OutputDescriptor, error) {
+	return DecodeOptions{}.DecodeReader(r)
+}
+
+// DecodeReaderContext decodes a serialized descriptor from r without
+// optional validation, stopping early if ctx is done.
+func DecodeReaderContext(ctx context.Context, r io.Reader) (OutputDescriptor, error) {
+	return DecodeOptions{}.DecodeReaderContext(ctx, r)
+}
+
+// DecodeReader decodes a serialized descriptor from r. The key maps
+// extend to the end of the input, so r is read until io.EOF.
+func (o DecodeOptions) DecodeReader(r io.Reader) (OutputDescriptor, error) {
+	return o.DecodeReaderContext(context.Background(), r)
+}
+
+// DecodeReaderContext is like DecodeReader, but checks ctx before
+// reading each map and returns its error, along with the number of
+// bytes read, if ctx is done. A read that blocks is not interrupted.
+func (o DecodeOptions) DecodeReaderContext(ctx context.Context, r io.Reader) (OutputDescriptor, error) {
+	if err := ctx.Err(); err != nil {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: %w after 0 bytes", err)
+	}
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(SerializeDescMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return OutputDescriptor{}, fmt.Errorf("serdesc: %w", psbt.ErrInvalidMagic)
+		}
+		return OutputDescriptor{}, fmt.Errorf("serdesc: %w", err)
+	}
+	if string(magic) != SerializeDescMagic {
+		return OutputDescriptor{}, fmt.Errorf("serdesc: %w", psbt.ErrInvalidMagic)
+	}
+	off := len(magic)
+	return o.decode(func() (psbt.Map, int, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, fmt.Errorf("%w after %d bytes", err, off)
+		}
+		m, n, err := psbt.ReadMap(br)
+		off += n
+		return m, n, err
+	})
+}
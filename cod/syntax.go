@@ -0,0 +1,200 @@
+package cod
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// This file implements a lightweight syntax check of descriptor
+// templates. It rejects malformed templates without interpreting the
+// script they describe.
+
+// ErrSyntax is returned for malformed descriptor templates.
+var ErrSyntax = errors.New("descriptor syntax error")
+
+// descFunctions is the set of descriptor and miniscript functions.
+var descFunctions = map[string]bool{
+	"sh": true, "wsh": true, "pk": true, "pkh": true, "wpkh": true,
+	"combo": true, "multi": true, "sortedmulti": true, "multi_a": true,
+	"sortedmulti_a": true, "tr": true, "rawtr": true, "addr": true,
+	"raw": true,
+	// Miniscript fragments.
+	"pk_k": true, "pk_h": true, "older": true, "after": true,
+	"sha256": true, "hash256": true, "ripemd160": true, "hash160": true,
+	"andor": true, "and_v": true, "and_b": true, "and_n": true,
+	"or_b": true, "or_c": true, "or_d": true, "or_i": true, "thresh": true,
+}
+
+// opaqueFunctions take a single argument that is not a descriptor
+// expression, such as an address or hex data.
+var opaqueFunctions = map[string]bool{
+	"addr": true, "raw": true,
+	"sha256": true, "hash256": true, "ripemd160": true, "hash160": true,
+}
+
+// miniscriptWrappers are the miniscript wrappers, which prefix
+// fragments separated by ':'.
+const miniscriptWrappers = "asctdvjnlu"
+
+// VerifySyntax checks that the descriptor template desc is well-formed:
+// parentheses and braces must match, functions must be known and keys
+// must be valid placeholders with derivation suffixes. Any checksum is
+// ignored. The error reports the offset of the offending character.
+func VerifySyntax(desc string) error {
+	tmpl, _, _ := strings.Cut(desc, "#")
+	var stack []byte
+	// arg tracks whether the current argument is non-empty.
+	arg := false
+	for i := 0; i < len(tmpl); {
+		c := tmpl[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+			if arg {
+				return syntaxError(i, "unexpected %q", c)
+			}
+			end := i
+			for end < len(tmpl) && ('a' <= tmpl[end] && tmpl[end] <= 'z' || tmpl[end] == '_' || tmpl[end] == ':' ||
+				'0' <= tmpl[end] && tmpl[end] <= '9') {
+				end++
+			}
+			name := tmpl[i:end]
+			if w, f, ok := strings.Cut(name, ":"); ok {
+				if w == "" || strings.Trim(w, miniscriptWrappers) != "" {
+					return syntaxError(i, "invalid wrappers %q", w)
+				}
+				name = f
+				i += len(w) + 1
+			}
+			if end == len(tmpl) || tmpl[end] != '(' {
+				return syntaxError(end, "missing '(' after %q", name)
+			}
+			if !descFunctions[name] {
+				return syntaxError(i, "unknown function %q", name)
+			}
+			i = end + 1
+			if opaqueFunctions[name] {
+				n := strings.IndexByte(tmpl[i:], ')')
+				if n <= 0 {
+					return syntaxError(i, "missing argument to %s", name)
+				}
+				i += n + 1
+				arg = true
+				continue
+			}
+			stack = append(stack, '(')
+		case '0' <= c && c <= '9':
+			if arg {
+				return syntaxError(i, "unexpected %q", c)
+			}
+			for i < len(tmpl) && '0' <= tmpl[i] && tmpl[i] <= '9' {
+				i++
+			}
+			arg = true
+		case c == '@':
+			if arg {
+				return syntaxError(i, "unexpected %q", c)
+			}
+			end, err := checkKey(tmpl, i)
+			if err != nil {
+				return err
+			}
+			i = end
+			arg = true
+		case c == '{':
+			if arg {
+				return syntaxError(i, "unexpected %q", c)
+			}
+			stack = append(stack, '{')
+			i++
+		case c == ',':
+			if len(stack) == 0 {
+				return syntaxError(i, "unexpected ','")
+			}
+			if !arg {
+				return syntaxError(i, "missing argument")
+			}
+			arg = false
+			i++
+		case c == ')' || c == '}':
+			open := byte('(')
+			if c == '}' {
+				open = '{'
+			}
+			if len(stack) == 0 || stack[len(stack)-1] != open {
+				return syntaxError(i, "unbalanced %q", c)
+			}
+			if !arg {
+				return syntaxError(i, "missing argument")
+			}
+			stack = stack[:len(stack)-1]
+			i++
+		default:
+			return syntaxError(i, "unexpected %q", c)
+		}
+	}
+	if len(stack) > 0 {
+		return syntaxError(len(tmpl), "unterminated %q", stack[len(stack)-1])
+	}
+	if !arg {
+		return syntaxError(len(tmpl), "empty descriptor")
+	}
+	return nil
+}
+
+// checkKey checks the key placeholder and derivation suffix starting
+// at tmpl[start] and returns its end position.
+func checkKey(tmpl string, start int) (int, error) {
+	_, i, err := parsePlaceholder(tmpl, start)
+	if err != nil {
+		return 0, syntaxError(start, "invalid key placeholder")
+	}
+	for i < len(tmpl) && tmpl[i] == '/' {
+		i++
+		switch {
+		case strings.HasPrefix(tmpl[i:], "**"):
+			return i + 2, nil
+		case i < len(tmpl) && tmpl[i] == '*':
+			i++
+		case i < len(tmpl) && tmpl[i] == '<':
+			end := strings.IndexByte(tmpl[i:], '>')
+			if end == -1 {
+				return 0, syntaxError(i, "unterminated multipath group")
+			}
+			for j, s := range strings.Split(tmpl[i+1:i+end], ";") {
+				if !validStep(s) {
+					return 0, syntaxError(i, "invalid multipath element %d", j)
+				}
+			}
+			i += end + 1
+		default:
+			end := i
+			for end < len(tmpl) && '0' <= tmpl[end] && tmpl[end] <= '9' {
+				end++
+			}
+			if end == i {
+				return 0, syntaxError(i, "invalid derivation step")
+			}
+			i = end
+		}
+		if i < len(tmpl) && (tmpl[i] == '\'' || tmpl[i] == 'h') {
+			i++
+		}
+	}
+	return i, nil
+}
+
+// validStep reports whether s is a derivation index,
+// optionally hardened.
+func validStep(s string) bool {
+	if n, ok := strings.CutSuffix(s, "'"); ok {
+		s = n
+	} else {
+		s = strings.TrimSuffix(s, "h")
+	}
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func syntaxError(offset int, format string, args ...any) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrSyntax, offset, fmt.Sprintf(format, args...))
+}
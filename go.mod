@@ -1,5 +1,3 @@
 module github.com/seedhammer/bip-serialized-descriptors
 
 go 1.21.1
-
-require github.com/btcsuite/btcutil v1.0.2
@@ -0,0 +1,72 @@
+// Package base58 implements the base58check encoding of Bitcoin
+// addresses and extended keys.
+package base58
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Alphabet is the base58 alphabet.
+const Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// CheckEncode encodes data followed by its 4 byte
+// double SHA256 checksum in base58.
+func CheckEncode(data []byte) string {
+	h := sha256.Sum256(data)
+	h = sha256.Sum256(h[:])
+	data = append(data[:len(data):len(data)], h[:4]...)
+	var enc []byte
+	x := new(big.Int).SetBytes(data)
+	radix := big.NewInt(int64(len(Alphabet)))
+	mod := new(big.Int)
+	for x.Sign() > 0 {
+		x.DivMod(x, radix, mod)
+		enc = append(enc, Alphabet[mod.Int64()])
+	}
+	// Leading zeros are encoded as the first character.
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		enc = append(enc, Alphabet[0])
+	}
+	for i, j := 0, len(enc)-1; i < j; i, j = i+1, j-1 {
+		enc[i], enc[j] = enc[j], enc[i]
+	}
+	return string(enc)
+}
+
+// CheckDecode decodes a base58 string and verifies
+// and strips its checksum.
+func CheckDecode(s string) ([]byte, error) {
+	x := new(big.Int)
+	radix := big.NewInt(int64(len(Alphabet)))
+	for _, c := range []byte(s) {
+		d := strings.IndexByte(Alphabet, c)
+		if d == -1 {
+			return nil, errors.New("invalid base58 character")
+		}
+		x.Mul(x, radix)
+		x.Add(x, big.NewInt(int64(d)))
+	}
+	var data []byte
+	// Leading first characters are decoded as zeros.
+	for i := 0; i < len(s) && s[i] == Alphabet[0]; i++ {
+		data = append(data, 0)
+	}
+	data = append(data, x.Bytes()...)
+	if len(data) < 4 {
+		return nil, errors.New("base58 data too short")
+	}
+	data, sum := data[:len(data)-4], data[len(data)-4:]
+	h := sha256.Sum256(data)
+	h = sha256.Sum256(h[:])
+	if !bytes.Equal(sum, h[:4]) {
+		return nil, errors.New("invalid base58 checksum")
+	}
+	return data, nil
+}
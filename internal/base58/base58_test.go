@@ -0,0 +1,60 @@
+package base58
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		data string
+		enc  string
+	}{
+		{"", "3QJmnh"},
+		// P2PKH address of the genesis block.
+		{"0062e907b15cbf27d5425399ebf6f0fb50ebb88f18", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{"0077bff20c60e522dfaa3350c39b030a5d004e839a", "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"},
+		{"007680adec8eabcabac676be9e83854ade0bd22cdb", "1BoatSLRHtKNngkdXEeobR76b53LETtpyT"},
+		// Leading zeros.
+		{"000000000000000000000000000000000000000000", "1111111111111111111114oLvT2"},
+		// Master key of BIP-32 test vector 1.
+		{
+			"0488b21e000000000000000000873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d5080339a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2",
+			"xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8",
+		},
+	}
+	for _, test := range tests {
+		data, err := hex.DecodeString(test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := CheckEncode(data); got != test.enc {
+			t.Errorf("CheckEncode(%s) = %s, want %s", test.data, got, test.enc)
+		}
+		got, err := CheckDecode(test.enc)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("CheckDecode(%s) = %x, %v, want %s", test.enc, got, err, test.data)
+		}
+	}
+}
+
+func TestCheckDecodeInvalid(t *testing.T) {
+	for _, s := range []string{
+		// Bad checksum.
+		"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb",
+		"1111111111111111111114oLvT3",
+		// Characters outside the alphabet.
+		"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfN0",
+		"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNO",
+		"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNI",
+		"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNl",
+		// Too short for a checksum.
+		"",
+		"111",
+	} {
+		if got, err := CheckDecode(s); err == nil {
+			t.Errorf("CheckDecode(%q) = %x, want error", s, got)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package bech32 implements the BIP-173 and BIP-350 encoding of
+// segregated witness addresses.
+package bech32
+
+import (
+	"errors"
+	"strings"
+)
+
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Checksum constants of bech32 and bech32m.
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+func polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (b>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func hrpExpand(hrp string) []byte {
+	v := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		v = append(v, hrp[i]>>5)
+	}
+	v = append(v, 0)
+	for i := 0; i < len(hrp); i++ {
+		v = append(v, hrp[i]&31)
+	}
+	return v
+}
+
+// encode returns the bech32 string of 5-bit data with the
+// given checksum constant.
+func encode(hrp string, data []byte, c uint32) string {
+	values := append(hrpExpand(hrp), data...)
+	mod := polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ c
+	var b strings.Builder
+	b.WriteString(hrp)
+	b.WriteByte('1')
+	for _, d := range data {
+		b.WriteByte(charset[d])
+	}
+	for i := 0; i < 6; i++ {
+		b.WriteByte(charset[(mod>>(5*(5-i)))&31])
+	}
+	return b.String()
+}
+
+// convertBits regroups 8-bit bytes into 5-bit groups, padding
+// the last group with zeros.
+func convertBits(data []byte) []byte {
+	var out []byte
+	acc, bits := uint32(0), 0
+	for _, d := range data {
+		acc = acc<<8 | uint32(d)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out = append(out, byte(acc>>bits)&31)
+		}
+	}
+	if bits > 0 {
+		out = append(out, byte(acc<<(5-bits))&31)
+	}
+	return out
+}
+
+// SegwitAddress returns the address of a witness program. Version 0
+// programs are encoded with bech32, later versions with bech32m.
+func SegwitAddress(hrp string, version byte, program []byte) (string, error) {
+	if version > 16 {
+		return "", errors.New("bech32: invalid witness version")
+	}
+	if len(program) < 2 || len(program) > 40 || (version == 0 && len(program) != 20 && len(program) != 32) {
+		return "", errors.New("bech32: invalid witness program length")
+	}
+	c := uint32(bech32Const)
+	if version > 0 {
+		c = bech32mConst
+	}
+	data := append([]byte{version}, convertBits(program)...)
+	return encode(hrp, data, c), nil
+}
@@ -0,0 +1,66 @@
+package bech32
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	// The empty strings of BIP-173 and BIP-350.
+	if got := encode("a", nil, bech32Const); got != "a12uel5l" {
+		t.Errorf("bech32 encoding %s, want a12uel5l", got)
+	}
+	if got := encode("a", nil, bech32mConst); got != "a1lqfn3a" {
+		t.Errorf("bech32m encoding %s, want a1lqfn3a", got)
+	}
+}
+
+// The vectors are from BIP-173 and BIP-350.
+func TestSegwitAddress(t *testing.T) {
+	tests := []struct {
+		hrp     string
+		version byte
+		program string
+		addr    string
+	}{
+		{"bc", 0, "751e76e8199196d454941c45d1b3a323f1433bd6", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"tb", 0, "1863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"},
+		{"tb", 0, "000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433", "tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy"},
+		{"bc", 1, "751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6", "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y"},
+		{"bc", 16, "751e", "bc1sw50qgdz25j"},
+		{"bc", 2, "751e76e8199196d454941c45d1b3a323", "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs"},
+		{"tb", 1, "000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433", "tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c"},
+		{"bc", 1, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+	}
+	for _, test := range tests {
+		program, err := hex.DecodeString(test.program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := SegwitAddress(test.hrp, test.version, program)
+		if err != nil || got != test.addr {
+			t.Errorf("SegwitAddress(%s, %d, %s) = %s, %v, want %s", test.hrp, test.version, test.program, got, err, test.addr)
+		}
+	}
+}
+
+func TestSegwitAddressInvalid(t *testing.T) {
+	tests := []struct {
+		version byte
+		length  int
+	}{
+		// Invalid witness version.
+		{17, 32},
+		// Invalid program lengths.
+		{1, 1},
+		{1, 41},
+		// Invalid program length for witness version 0.
+		{0, 16},
+		{0, 21},
+	}
+	for _, test := range tests {
+		if got, err := SegwitAddress("bc", test.version, make([]byte, test.length)); err == nil {
+			t.Errorf("SegwitAddress of version %d and length %d = %s, want error", test.version, test.length, got)
+		}
+	}
+}
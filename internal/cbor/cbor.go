@@ -0,0 +1,272 @@
+// Package cbor implements the subset of CBOR (RFC 8949) needed by
+// the Blockchain Commons UR types: unsigned integers, byte and text
+// strings, arrays, maps, tags and booleans of definite length.
+package cbor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Major types.
+const (
+	MajorUint   = 0
+	MajorBytes  = 2
+	MajorText   = 3
+	MajorArray  = 4
+	MajorMap    = 5
+	MajorTag    = 6
+	MajorSimple = 7
+)
+
+const (
+	simpleFalse = 20
+	simpleTrue  = 21
+)
+
+// Encoder appends CBOR items to a buffer. Map entries must be
+// added in canonical order by the caller.
+type Encoder struct {
+	buf []byte
+}
+
+// Bytes returns the encoded items.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+func (e *Encoder) header(major uint8, v uint64) {
+	m := major << 5
+	switch {
+	case v < 24:
+		e.buf = append(e.buf, m|uint8(v))
+	case v <= 0xff:
+		e.buf = append(e.buf, m|24, uint8(v))
+	case v <= 0xffff:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, m|25), uint16(v))
+	case v <= 0xffffffff:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, m|26), uint32(v))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, m|27), v)
+	}
+}
+
+func (e *Encoder) Uint(v uint64) {
+	e.header(MajorUint, v)
+}
+
+func (e *Encoder) ByteString(b []byte) {
+	e.header(MajorBytes, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *Encoder) Text(s string) {
+	e.header(MajorText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// Array starts an array of n items.
+func (e *Encoder) Array(n int) {
+	e.header(MajorArray, uint64(n))
+}
+
+// Map starts a map of n key-value pairs.
+func (e *Encoder) Map(n int) {
+	e.header(MajorMap, uint64(n))
+}
+
+// Tag tags the following item.
+func (e *Encoder) Tag(t uint64) {
+	e.header(MajorTag, t)
+}
+
+func (e *Encoder) Bool(b bool) {
+	if b {
+		e.header(MajorSimple, simpleTrue)
+	} else {
+		e.header(MajorSimple, simpleFalse)
+	}
+}
+
+// ErrTruncated is returned when the input ends inside an item.
+var ErrTruncated = errors.New("cbor: truncated data")
+
+// maxDepth limits the nesting of items skipped by Skip.
+const maxDepth = 32
+
+// Decoder reads CBOR items from a byte slice.
+type Decoder struct {
+	data []byte
+}
+
+// NewDecoder returns a decoder reading from data.
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data}
+}
+
+// Len returns the number of unread bytes.
+func (d *Decoder) Len() int {
+	return len(d.data)
+}
+
+// Peek returns the major type of the next item.
+func (d *Decoder) Peek() (uint8, error) {
+	if len(d.data) == 0 {
+		return 0, ErrTruncated
+	}
+	return d.data[0] >> 5, nil
+}
+
+// header reads an item header.
+func (d *Decoder) header() (uint8, uint64, error) {
+	if len(d.data) == 0 {
+		return 0, 0, ErrTruncated
+	}
+	major, info := d.data[0]>>5, d.data[0]&0x1f
+	n := 0
+	switch {
+	case info < 24:
+		d.data = d.data[1:]
+		return major, uint64(info), nil
+	case info == 24:
+		n = 1
+	case info == 25:
+		n = 2
+	case info == 26:
+		n = 4
+	case info == 27:
+		n = 8
+	default:
+		return 0, 0, fmt.Errorf("cbor: unsupported additional information %d", info)
+	}
+	if len(d.data) < 1+n {
+		return 0, 0, ErrTruncated
+	}
+	var v uint64
+	for _, b := range d.data[1 : 1+n] {
+		v = v<<8 | uint64(b)
+	}
+	d.data = d.data[1+n:]
+	return major, v, nil
+}
+
+func (d *Decoder) expect(major uint8) (uint64, error) {
+	m, v, err := d.header()
+	if err != nil {
+		return 0, err
+	}
+	if m != major {
+		return 0, fmt.Errorf("cbor: unexpected major type %d, expected %d", m, major)
+	}
+	return v, nil
+}
+
+func (d *Decoder) Uint() (uint64, error) {
+	return d.expect(MajorUint)
+}
+
+func (d *Decoder) ByteString() ([]byte, error) {
+	return d.readString(MajorBytes)
+}
+
+func (d *Decoder) Text() (string, error) {
+	b, err := d.readString(MajorText)
+	return string(b), err
+}
+
+func (d *Decoder) readString(major uint8) ([]byte, error) {
+	n, err := d.expect(major)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.data)) {
+		return nil, ErrTruncated
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b, nil
+}
+
+// Array reads the header of an array and returns its length.
+func (d *Decoder) Array() (int, error) {
+	return d.length(MajorArray)
+}
+
+// Map reads the header of a map and returns its number of pairs.
+func (d *Decoder) Map() (int, error) {
+	return d.length(MajorMap)
+}
+
+func (d *Decoder) length(major uint8) (int, error) {
+	n, err := d.expect(major)
+	if err != nil {
+		return 0, err
+	}
+	// Every item is at least one byte.
+	if n > uint64(len(d.data)) {
+		return 0, ErrTruncated
+	}
+	return int(n), nil
+}
+
+// Tag reads a tag.
+func (d *Decoder) Tag() (uint64, error) {
+	return d.expect(MajorTag)
+}
+
+func (d *Decoder) Bool() (bool, error) {
+	v, err := d.expect(MajorSimple)
+	if err != nil {
+		return false, err
+	}
+	switch v {
+	case simpleFalse:
+		return false, nil
+	case simpleTrue:
+		return true, nil
+	default:
+		return false, fmt.Errorf("cbor: unexpected simple value %d", v)
+	}
+}
+
+// Skip skips the next item, including nested items.
+func (d *Decoder) Skip() error {
+	return d.skip(0)
+}
+
+func (d *Decoder) skip(depth int) error {
+	if depth > maxDepth {
+		return errors.New("cbor: nesting too deep")
+	}
+	m, v, err := d.header()
+	if err != nil {
+		return err
+	}
+	switch m {
+	case MajorBytes, MajorText:
+		if v > uint64(len(d.data)) {
+			return ErrTruncated
+		}
+		d.data = d.data[v:]
+	case MajorArray, MajorMap:
+		if v > uint64(len(d.data)) {
+			return ErrTruncated
+		}
+		n := v
+		if m == MajorMap {
+			n *= 2
+		}
+		for i := uint64(0); i < n; i++ {
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+		}
+	case MajorTag:
+		return d.skip(depth + 1)
+	case MajorUint, MajorSimple:
+	default:
+		return fmt.Errorf("cbor: unsupported major type %d", m)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// The vectors are from RFC 8949, Appendix A.
+var encodeVectors = []struct {
+	enc  func(e *Encoder)
+	want string
+}{
+	{func(e *Encoder) { e.Uint(0) }, "00"},
+	{func(e *Encoder) { e.Uint(23) }, "17"},
+	{func(e *Encoder) { e.Uint(24) }, "1818"},
+	{func(e *Encoder) { e.Uint(100) }, "1864"},
+	{func(e *Encoder) { e.Uint(1000) }, "1903e8"},
+	{func(e *Encoder) { e.Uint(1000000) }, "1a000f4240"},
+	{func(e *Encoder) { e.Uint(1000000000000) }, "1b000000e8d4a51000"},
+	{func(e *Encoder) { e.Uint(18446744073709551615) }, "1bffffffffffffffff"},
+	{func(e *Encoder) { e.Bool(false) }, "f4"},
+	{func(e *Encoder) { e.Bool(true) }, "f5"},
+	{func(e *Encoder) { e.ByteString(nil) }, "40"},
+	{func(e *Encoder) { e.ByteString([]byte{1, 2, 3, 4}) }, "4401020304"},
+	{func(e *Encoder) { e.Text("") }, "60"},
+	{func(e *Encoder) { e.Text("a") }, "6161"},
+	{func(e *Encoder) { e.Text("IETF") }, "6449455446"},
+	{func(e *Encoder) { e.Text("ü") }, "62c3bc"},
+	{func(e *Encoder) { e.Array(0) }, "80"},
+	{func(e *Encoder) { e.Array(3); e.Uint(1); e.Uint(2); e.Uint(3) }, "83010203"},
+	{func(e *Encoder) { e.Map(0) }, "a0"},
+	{func(e *Encoder) { e.Map(2); e.Uint(1); e.Uint(2); e.Uint(3); e.Uint(4) }, "a201020304"},
+	{func(e *Encoder) { e.Tag(1); e.Uint(1363896240) }, "c11a514b67b0"},
+	{func(e *Encoder) { e.Tag(23); e.ByteString([]byte{1, 2, 3, 4}) }, "d74401020304"},
+	{func(e *Encoder) { e.Tag(32); e.Text("http://www.example.com") }, "d82076687474703a2f2f7777772e6578616d706c652e636f6d"},
+}
+
+func TestEncode(t *testing.T) {
+	for _, v := range encodeVectors {
+		e := new(Encoder)
+		v.enc(e)
+		if got := hex.EncodeToString(e.Bytes()); got != v.want {
+			t.Errorf("encoded %s, want %s", got, v.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	d := NewDecoder(mustHex(t, "a2011a000f424002831864d82076687474703a2f2f7777772e6578616d706c652e636f6d4401020304"))
+	n, err := d.Map()
+	if err != nil || n != 2 {
+		t.Fatalf("Map() = %d, %v", n, err)
+	}
+	if k, err := d.Uint(); err != nil || k != 1 {
+		t.Fatalf("key = %d, %v", k, err)
+	}
+	if v, err := d.Uint(); err != nil || v != 1000000 {
+		t.Fatalf("value = %d, %v", v, err)
+	}
+	if k, err := d.Uint(); err != nil || k != 2 {
+		t.Fatalf("key = %d, %v", k, err)
+	}
+	if n, err := d.Array(); err != nil || n != 3 {
+		t.Fatalf("Array() = %d, %v", n, err)
+	}
+	if v, err := d.Uint(); err != nil || v != 100 {
+		t.Fatalf("element = %d, %v", v, err)
+	}
+	if err := d.Skip(); err != nil {
+		t.Fatalf("Skip() of tagged text: %v", err)
+	}
+	if b, err := d.ByteString(); err != nil || !bytes.Equal(b, []byte{1, 2, 3, 4}) {
+		t.Fatalf("ByteString() = %x, %v", b, err)
+	}
+	if d.Len() != 0 {
+		t.Fatalf("%d bytes left", d.Len())
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	for _, s := range []string{"", "18", "1903", "4401", "6449", "d8"} {
+		d := NewDecoder(mustHex(t, s))
+		if err := d.Skip(); !errors.Is(err, ErrTruncated) {
+			t.Errorf("Skip() of %q returned %v, want %v", s, err, ErrTruncated)
+		}
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
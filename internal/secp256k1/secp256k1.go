@@ -0,0 +1,155 @@
+// Package secp256k1 implements the secp256k1 group operations needed
+// for public key derivation. It is not constant time and must only be
+// used with public data.
+package secp256k1
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+var (
+	// P is the order of the underlying field.
+	P, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+	// N is the order of the group.
+	N, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	// G is the generator.
+	G = Point{
+		X: hexInt("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"),
+		Y: hexInt("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"),
+	}
+)
+
+func hexInt(s string) *big.Int {
+	v, _ := new(big.Int).SetString(s, 16)
+	return v
+}
+
+// Point is a point on the curve in affine coordinates. The zero
+// Point is the point at infinity.
+type Point struct {
+	X, Y *big.Int
+}
+
+// IsInfinity reports whether p is the point at infinity.
+func (p Point) IsInfinity() bool {
+	return p.X == nil
+}
+
+// ParseCompressed parses a 33 byte compressed point.
+func ParseCompressed(b []byte) (Point, error) {
+	if len(b) != 33 || (b[0] != 0x02 && b[0] != 0x03) {
+		return Point{}, errors.New("secp256k1: invalid compressed point")
+	}
+	p, err := LiftX(b[1:])
+	if err != nil {
+		return Point{}, err
+	}
+	if p.Y.Bit(0) != uint(b[0]&1) {
+		p.Y.Sub(P, p.Y)
+	}
+	return p, nil
+}
+
+// LiftX returns the point with the given 32 byte x coordinate
+// and an even y coordinate.
+func LiftX(x []byte) (Point, error) {
+	px := new(big.Int).SetBytes(x)
+	if len(x) != 32 || px.Cmp(P) >= 0 {
+		return Point{}, errors.New("secp256k1: invalid x coordinate")
+	}
+	// y^2 = x^3 + 7.
+	y2 := new(big.Int).Exp(px, big.NewInt(3), P)
+	y2.Add(y2, big.NewInt(7))
+	y2.Mod(y2, P)
+	y := new(big.Int).ModSqrt(y2, P)
+	if y == nil {
+		return Point{}, errors.New("secp256k1: point not on curve")
+	}
+	if y.Bit(0) != 0 {
+		y.Sub(P, y)
+	}
+	return Point{X: px, Y: y}, nil
+}
+
+// Compressed returns the 33 byte compressed encoding of p.
+func (p Point) Compressed() []byte {
+	b := make([]byte, 33)
+	b[0] = 0x02 | byte(p.Y.Bit(0))
+	p.X.FillBytes(b[1:])
+	return b
+}
+
+// XOnly returns the 32 byte x coordinate of p.
+func (p Point) XOnly() []byte {
+	return p.X.FillBytes(make([]byte, 32))
+}
+
+// Add returns a + b.
+func Add(a, b Point) Point {
+	switch {
+	case a.IsInfinity():
+		return b
+	case b.IsInfinity():
+		return a
+	}
+	lambda := new(big.Int)
+	if a.X.Cmp(b.X) == 0 {
+		if a.Y.Cmp(b.Y) != 0 || a.Y.Sign() == 0 {
+			return Point{}
+		}
+		// lambda = 3x^2 / 2y.
+		lambda.Mul(a.X, a.X)
+		lambda.Mul(lambda, big.NewInt(3))
+		den := new(big.Int).Lsh(a.Y, 1)
+		lambda.Mul(lambda, den.ModInverse(den, P))
+	} else {
+		// lambda = (y2 - y1) / (x2 - x1).
+		lambda.Sub(b.Y, a.Y)
+		den := new(big.Int).Sub(b.X, a.X)
+		den.Mod(den, P)
+		lambda.Mul(lambda, den.ModInverse(den, P))
+	}
+	lambda.Mod(lambda, P)
+	x := new(big.Int).Mul(lambda, lambda)
+	x.Sub(x, a.X)
+	x.Sub(x, b.X)
+	x.Mod(x, P)
+	y := new(big.Int).Sub(a.X, x)
+	y.Mul(y, lambda)
+	y.Sub(y, a.Y)
+	y.Mod(y, P)
+	return Point{X: x, Y: y}
+}
+
+// ScalarMult returns k*p.
+func ScalarMult(p Point, k *big.Int) Point {
+	var r Point
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		r = Add(r, r)
+		if k.Bit(i) == 1 {
+			r = Add(r, p)
+		}
+	}
+	return r
+}
+
+// ScalarBaseMult returns k*G.
+func ScalarBaseMult(k *big.Int) Point {
+	return ScalarMult(G, k)
+}
+
+// TaggedHash returns the BIP-340 tagged hash of msg.
+func TaggedHash(tag string, msg ...[]byte) [32]byte {
+	th := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(th[:])
+	h.Write(th[:])
+	for _, m := range msg {
+		h.Write(m)
+	}
+	var sum [32]byte
+	h.Sum(sum[:0])
+	return sum
+}
@@ -0,0 +1,86 @@
+package secp256k1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestScalarBaseMult(t *testing.T) {
+	tests := []struct {
+		k    int64
+		want string
+	}{
+		{1, "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+		{2, "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"},
+		{3, "02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9"},
+	}
+	for _, test := range tests {
+		p := ScalarBaseMult(big.NewInt(test.k))
+		if got := hex.EncodeToString(p.Compressed()); got != test.want {
+			t.Errorf("%d*G = %s, want %s", test.k, got, test.want)
+		}
+	}
+	if p := ScalarBaseMult(N); !p.IsInfinity() {
+		t.Errorf("N*G = %x, want infinity", p.Compressed())
+	}
+	// G + 2G = 3G.
+	sum := Add(G, ScalarBaseMult(big.NewInt(2)))
+	if !bytes.Equal(sum.Compressed(), ScalarBaseMult(big.NewInt(3)).Compressed()) {
+		t.Errorf("G + 2G = %x, want 3G", sum.Compressed())
+	}
+	// G - G = infinity.
+	neg := Point{X: G.X, Y: new(big.Int).Sub(P, G.Y)}
+	if p := Add(G, neg); !p.IsInfinity() {
+		t.Errorf("G - G = %x, want infinity", p.Compressed())
+	}
+}
+
+func TestParseCompressed(t *testing.T) {
+	for _, s := range []string{
+		"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		"0379be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		"02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5",
+	} {
+		b := mustHex(t, s)
+		p, err := ParseCompressed(b)
+		if err != nil {
+			t.Errorf("ParseCompressed(%s): %v", s, err)
+			continue
+		}
+		if got := p.Compressed(); !bytes.Equal(got, b) {
+			t.Errorf("ParseCompressed(%s) re-encoded as %x", s, got)
+		}
+	}
+}
+
+func TestParseCompressedInvalid(t *testing.T) {
+	for _, s := range []string{
+		// Invalid lengths.
+		"",
+		"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f817",
+		"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f8179800",
+		// Invalid prefixes.
+		"0079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		"0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		// No point has x coordinate 0.
+		"020000000000000000000000000000000000000000000000000000000000000000",
+		// x coordinate equal to and above the field size.
+		"02fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f",
+		"02ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+	} {
+		if _, err := ParseCompressed(mustHex(t, s)); err == nil {
+			t.Errorf("ParseCompressed(%s) succeeded", s)
+		}
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -6,11 +6,12 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
+	"os"
 	"reflect"
 
-	"github.com/btcsuite/btcutil/base58"
 	"github.com/seedhammer/bip-serialized-descriptors/cod"
 	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+	"github.com/seedhammer/bip-serialized-descriptors/ur"
 )
 
 func main() {
@@ -19,32 +20,17 @@ func main() {
 }
 
 func demoBIPSerializeDesc() {
-	path := []uint32{
-		0x48 + cod.HardenedKeyStart,
-		0x00 + cod.HardenedKeyStart,
-		0x00 + cod.HardenedKeyStart,
-		0x02 + cod.HardenedKeyStart,
-	}
-	desc := cod.OutputDescriptor{
-		Name:       "Satoshi's Stash",
-		Descriptor: "wsh(sortedmulti(2,@0/<0;1>/*,@1/<0;1>/*,@2/<0;1>/*))",
-		Keys: []psbt.ExtendedKey{
-			{
-				MasterFingerprint: 0xdc567276,
-				Path:              path,
-				Key:               base58.Decode("xpub6DiYrfRwNnjeX4vHsWMajJVFKrbEEnu8gAW9vDuQzgTWEsEHE16sGWeXXUV1LBWQE1yCTmeprSNcqZ3W74hqVdgDbtYHUv3eM4W2TEUhpan"),
-			},
-			{
-				MasterFingerprint: 0xf245ae38,
-				Path:              path,
-				Key:               base58.Decode("xpub6DnT4E1fT8VxuAZW29avMjr5i99aYTHBp9d7fiLnpL5t4JEprQqPMbTw7k7rh5tZZ2F5g8PJpssqrZoebzBChaiJrmEvWwUTEMAbHsY39Ge"),
-			},
-			{
-				MasterFingerprint: 0xc5d87297,
-				Path:              path,
-				Key:               base58.Decode("xpub6DjrnfAyuonMaboEb3ZQZzhQ2ZEgaKV2r64BFmqymZqJqviLTe1JzMr2X2RfQF892RH7MyYUbcy77R7pPu1P71xoj8cDUMNhAMGYzKR4noZ"),
-			},
+	desc, err := cod.OutputDescriptorFromStrings(
+		"Satoshi's Stash",
+		"wsh(sortedmulti(2,@0/<0;1>/*,@1/<0;1>/*,@2/<0;1>/*))",
+		[]string{
+			"[dc567276/48'/0'/0'/2']xpub6DiYrfRwNnjeX4vHsWMajJVFKrbEEnu8gAW9vDuQzgTWEsEHE16sGWeXXUV1LBWQE1yCTmeprSNcqZ3W74hqVdgDbtYHUv3eM4W2TEUhpan",
+			"[f245ae38/48'/0'/0'/2']xpub6DnT4E1fT8VxuAZW29avMjr5i99aYTHBp9d7fiLnpL5t4JEprQqPMbTw7k7rh5tZZ2F5g8PJpssqrZoebzBChaiJrmEvWwUTEMAbHsY39Ge",
+			"[c5d87297/48'/0'/0'/2']xpub6DjrnfAyuonMaboEb3ZQZzhQ2ZEgaKV2r64BFmqymZqJqviLTe1JzMr2X2RfQF892RH7MyYUbcy77R7pPu1P71xoj8cDUMNhAMGYzKR4noZ",
 		},
+	)
+	if err != nil {
+		panic(err)
 	}
 	enc, err := cod.Encode(desc)
 	if err != nil {
@@ -61,15 +47,24 @@ func demoBIPSerializeDesc() {
 	fmt.Printf("Name: %s\n", decodedDesc.Name)
 	fmt.Printf("Descriptor: %s\n", decodedDesc.Descriptor)
 	for _, k := range decodedDesc.Keys {
-		fmt.Printf("xpub: [%x", k.MasterFingerprint)
-		for _, p := range k.Path {
-			if p >= cod.HardenedKeyStart {
-				fmt.Printf("/%xh", p-cod.HardenedKeyStart)
-			} else {
-				fmt.Printf("/%x", p)
-			}
+		fmt.Printf("xpub: %s\n", k.OriginString())
+	}
+	addr, err := decodedDesc.Address(0, 0)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("First address: %s\n", addr)
+
+	outputs, err := cod.EncodeCryptoOutputs(desc)
+	if err != nil {
+		panic(err)
+	}
+	for _, output := range outputs {
+		parts := ur.NewEncoder("crypto-output", output, 200)
+		fmt.Printf("\nAs %d ur:crypto-output parts:\n", parts.SeqLen())
+		for i := 0; i < parts.SeqLen(); i++ {
+			fmt.Println(parts.NextPart())
 		}
-		fmt.Printf("]%s\n", base58.Encode(k.Key))
 	}
 }
 
@@ -78,9 +73,10 @@ func demoPSBT() {
 	if err != nil {
 		panic(err)
 	}
-	fmt.Println("Parsed PSBT:")
-	err = psbt.Decode(p)
+	decoded, err := psbt.Decode(p)
 	if err != nil {
 		log.Fatal(err)
 	}
+	fmt.Println("Parsed PSBT:")
+	psbt.Dump(os.Stdout, decoded)
 }
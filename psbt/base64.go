@@ -0,0 +1,87 @@
+package psbt
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// DecodeBase64 decodes a base64 encoded PSBT with the default options.
+func DecodeBase64(s string) (PSBT, error) {
+	return DecodeOptions{}.DecodeBase64(s)
+}
+
+// DecodeBase64 decodes a base64 encoded PSBT. White space, such as the
+// line breaks of copied and pasted PSBTs, is ignored.
+func (o DecodeOptions) DecodeBase64(s string) (PSBT, error) {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+	if base64.StdEncoding.DecodedLen(len(s)) > o.maxSize() {
+		return PSBT{}, fmt.Errorf("psbt: %w: %d base64 characters", ErrTooLarge, len(s))
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return PSBT{}, fmt.Errorf("psbt: %w", err)
+	}
+	return o.Decode(data)
+}
+
+// EncodeBase64 encodes p in base64, as used by Bitcoin Core.
+func EncodeBase64(p PSBT) (string, error) {
+	data, err := Encode(p)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// ScanPSBT decodes a PSBT from r with the default options.
+func ScanPSBT(r io.Reader) (PSBT, error) {
+	return DecodeOptions{}.ScanPSBT(r)
+}
+
+// ScanPSBT decodes a PSBT from r in the forms users commonly supply:
+// binary, or base64 text as accepted by DecodeBase64. The text may be
+// wrapped in armor lines starting with "-----", such as
+// "-----BEGIN PSBT-----", and prefixed by "psbt:". Like Decode, data
+// following a binary PSBT is rejected with ErrTrailingData.
+func (o DecodeOptions) ScanPSBT(r io.Reader) (PSBT, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(psbtMagic)); string(magic) == psbtMagic {
+		data, err := io.ReadAll(io.LimitReader(br, int64(o.maxSize())+1))
+		if err != nil {
+			return PSBT{}, fmt.Errorf("psbt: %w", err)
+		}
+		return o.Decode(data)
+	}
+	// Allow for line breaks and armor around the base64 encoding.
+	limit := 2 * int64(base64.StdEncoding.EncodedLen(o.maxSize()))
+	text, err := io.ReadAll(io.LimitReader(br, limit+1))
+	if err != nil {
+		return PSBT{}, fmt.Errorf("psbt: %w", err)
+	}
+	if int64(len(text)) > limit {
+		return PSBT{}, fmt.Errorf("psbt: %w: more than %d characters", ErrTooLarge, limit)
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(string(text), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "-----") {
+			continue
+		}
+		b.WriteString(line)
+	}
+	s := b.String()
+	const prefix = "psbt:"
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		s = s[len(prefix):]
+	}
+	return o.DecodeBase64(s)
+}
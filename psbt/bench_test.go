@@ -0,0 +1,100 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// testCosigners are the keys of a 2-of-3 multisig.
+var testCosigners = []string{
+	"[dc567276/48'/0'/0'/2']xpub6DiYrfRwNnjeX4vHsWMajJVFKrbEEnu8gAW9vDuQzgTWEsEHE16sGWeXXUV1LBWQE1yCTmeprSNcqZ3W74hqVdgDbtYHUv3eM4W2TEUhpan",
+	"[f245ae38/48'/0'/0'/2']xpub6DnT4E1fT8VxuAZW29avMjr5i99aYTHBp9d7fiLnpL5t4JEprQqPMbTw7k7rh5tZZ2F5g8PJpssqrZoebzBChaiJrmEvWwUTEMAbHsY39Ge",
+	"[c5d87297/48'/0'/0'/2']xpub6DjrnfAyuonMaboEb3ZQZzhQ2ZEgaKV2r64BFmqymZqJqviLTe1JzMr2X2RfQF892RH7MyYUbcy77R7pPu1P71xoj8cDUMNhAMGYzKR4noZ",
+}
+
+// BenchmarkDecodeMap measures decoding every map of a PSBT
+// with two finalized inputs and two outputs.
+func BenchmarkDecodeMap(b *testing.B) {
+	data, err := base64.StdEncoding.DecodeString(testPSBTs[2].base64)
+	if err != nil {
+		b.Fatal(err)
+	}
+	maps := data[len(psbtMagic):]
+	b.ReportAllocs()
+	b.SetBytes(int64(len(maps)))
+	for i := 0; i < b.N; i++ {
+		for rest := maps; len(rest) > 0; {
+			_, n, err := DecodeMap(rest)
+			if err != nil {
+				b.Fatal(err)
+			}
+			rest = rest[n:]
+		}
+	}
+}
+
+// BenchmarkDecodePSBTXpub measures decoding the PSBT_GLOBAL_XPUB
+// entries of the cosigners of a multisig.
+func BenchmarkDecodePSBTXpub(b *testing.B) {
+	var entries []Entry
+	for _, s := range testCosigners {
+		k, err := ParseExtendedKey(s)
+		if err != nil {
+			b.Fatal(err)
+		}
+		val := binary.BigEndian.AppendUint32(nil, k.MasterFingerprint)
+		for _, p := range k.Path {
+			val = binary.LittleEndian.AppendUint32(val, p)
+		}
+		entries = append(entries, Entry{Key: append([]byte{PSBT_GLOBAL_XPUB}, k.Key...), Val: val})
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, e := range entries {
+			if _, err := DecodePSBTXpub(e); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// derivationMap returns an input map with n PSBT_IN_BIP32_DERIVATION
+// entries, such as for a large multisig.
+func derivationMap(n int) Map {
+	m := make(Map, n)
+	for i := range m {
+		key := make([]byte, 34)
+		key[0] = PSBT_IN_BIP32_DERIVATION
+		key[1] = 0x02
+		binary.BigEndian.PutUint32(key[2:], uint32(i))
+		val := binary.BigEndian.AppendUint32(nil, 0xd34db33f)
+		for _, p := range []uint32{HardenedKeyStart + 48, HardenedKeyStart, HardenedKeyStart, HardenedKeyStart + 2, 0, uint32(i)} {
+			val = binary.LittleEndian.AppendUint32(val, p)
+		}
+		m[i] = Entry{Key: key, Val: val}
+	}
+	return m
+}
+
+// BenchmarkDecodeMapSize measures DecodeMap for growing maps. The
+// entry slice is preallocated in a single allocation; the remaining
+// allocation per entry is the key of the duplicate check.
+func BenchmarkDecodeMapSize(b *testing.B) {
+	for _, n := range []int{1, 10, 100, 1000} {
+		buf := new(bytes.Buffer)
+		EncodeMap(buf, derivationMap(n))
+		data := buf.Bytes()
+		b.Run(fmt.Sprintf("entries=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, _, err := DecodeMap(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package psbt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	// ErrTxMismatch is returned by Combine for PSBTs of
+	// different transactions.
+	ErrTxMismatch = errors.New("unsigned transactions differ")
+	// ErrConflict is returned by Combine when PSBTs have
+	// different values for the same key.
+	ErrConflict = errors.New("conflicting values")
+)
+
+// Combine implements the BIP-174 Combiner role. It merges the entries
+// of PSBTs for the same transaction, such as copies signed by
+// different cosigners. Identical entries are included once, while
+// different values for the same key is an error.
+func Combine(psbts ...PSBT) (PSBT, error) {
+	if len(psbts) == 0 {
+		return PSBT{}, errors.New("psbt: nothing to combine")
+	}
+	first := psbts[0]
+	global := slices.Clone(first.Global.Entries)
+	inputs := make([]Map, len(first.Inputs))
+	for i, in := range first.Inputs {
+		inputs[i] = slices.Clone(in.Entries)
+	}
+	outputs := make([]Map, len(first.Outputs))
+	for i, out := range first.Outputs {
+		outputs[i] = slices.Clone(out.Entries)
+	}
+	for i, p := range psbts[1:] {
+		if err := sameTransaction(first, p); err != nil {
+			return PSBT{}, fmt.Errorf("psbt: combine %d: %w", i+1, err)
+		}
+		var err error
+		global, err = mergeMap(global, p.Global.Entries)
+		if err != nil {
+			return PSBT{}, fmt.Errorf("psbt: combine %d: global: %w", i+1, err)
+		}
+		for j, in := range p.Inputs {
+			inputs[j], err = mergeMap(inputs[j], in.Entries)
+			if err != nil {
+				return PSBT{}, fmt.Errorf("psbt: combine %d: input %d: %w", i+1, j, err)
+			}
+		}
+		for j, out := range p.Outputs {
+			outputs[j], err = mergeMap(outputs[j], out.Entries)
+			if err != nil {
+				return PSBT{}, fmt.Errorf("psbt: combine %d: output %d: %w", i+1, j, err)
+			}
+		}
+	}
+	g, err := decodeGlobal(global)
+	if err != nil {
+		return PSBT{}, fmt.Errorf("psbt: combine: %w", err)
+	}
+	res := PSBT{Global: g}
+	for i, m := range inputs {
+		in, err := decodeInput(m)
+		if err != nil {
+			return PSBT{}, fmt.Errorf("psbt: combine: input %d: %w", i, err)
+		}
+		res.Inputs = append(res.Inputs, in)
+	}
+	for i, m := range outputs {
+		out, err := decodeOutput(m)
+		if err != nil {
+			return PSBT{}, fmt.Errorf("psbt: combine: output %d: %w", i, err)
+		}
+		res.Outputs = append(res.Outputs, out)
+	}
+	return res, nil
+}
+
+// sameTransaction reports an error if a and b don't describe the
+// same transaction. Differences in the version 2 transaction fields
+// are caught as conflicts when merging.
+func sameTransaction(a, b PSBT) error {
+	ga, gb := a.Global, b.Global
+	if ga.Version != gb.Version {
+		return fmt.Errorf("%w: versions %d and %d", ErrTxMismatch, ga.Version, gb.Version)
+	}
+	if len(a.Inputs) != len(b.Inputs) || len(a.Outputs) != len(b.Outputs) {
+		return fmt.Errorf("%w: input or output counts", ErrTxMismatch)
+	}
+	if ga.Version < 2 {
+		txa, _ := ga.Entries.Get(PSBT_GLOBAL_UNSIGNED_TX)
+		txb, _ := gb.Entries.Get(PSBT_GLOBAL_UNSIGNED_TX)
+		if !bytes.Equal(txa.Val, txb.Val) {
+			return ErrTxMismatch
+		}
+	}
+	return nil
+}
+
+// mergeMap adds the entries of src missing from dst.
+func mergeMap(dst, src Map) (Map, error) {
+	for _, e := range src {
+		if d, ok := dst.lookup(e.Key); ok {
+			if !bytes.Equal(d.Val, e.Val) {
+				return nil, fmt.Errorf("%w for key %x", ErrConflict, e.Key)
+			}
+			continue
+		}
+		dst = append(dst, e)
+	}
+	return dst, nil
+}
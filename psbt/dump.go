@@ -0,0 +1,82 @@
+package psbt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// This file implements a human-readable dump of PSBTs for debugging.
+
+// Dump writes a description of every entry of p to w, one line per
+// entry, with field types named by KeyTypeName. Entries that decode
+// are shown in decoded form, others as hex. Write errors are ignored.
+func Dump(w io.Writer, p PSBT) {
+	fmt.Fprintln(w, "Global map:")
+	tx := p.Global.UnsignedTx
+	dumpMap(w, p.Global.Entries, ScopeGlobal, func(e Entry) string {
+		switch e.Key[0] {
+		case PSBT_GLOBAL_UNSIGNED_TX:
+			return fmt.Sprintf("version %d, %d inputs, %d outputs", tx.Version, len(tx.Inputs), len(tx.Outputs))
+		case PSBT_GLOBAL_XPUB:
+			if k, err := DecodePSBTXpub(e); err == nil {
+				return k.OriginString()
+			}
+		}
+		return ""
+	})
+	for i, in := range p.Inputs {
+		fmt.Fprintf(w, "\nInput map %d:\n", i)
+		dumpMap(w, in.Entries, ScopeInput, func(e Entry) string {
+			switch e.Key[0] {
+			case PSBT_IN_WITNESS_UTXO:
+				if in.WitnessUTXO != nil {
+					return fmt.Sprintf("value %d, script %x", in.WitnessUTXO.Value, in.WitnessUTXO.ScriptPubKey)
+				}
+			case PSBT_IN_SIGHASH_TYPE:
+				return in.SighashType.String()
+			case PSBT_IN_BIP32_DERIVATION:
+				return dumpDerivation(e)
+			}
+			return ""
+		})
+	}
+	for i, out := range p.Outputs {
+		fmt.Fprintf(w, "\nOutput map %d:\n", i)
+		dumpMap(w, out.Entries, ScopeOutput, func(e Entry) string {
+			if e.Key[0] == PSBT_OUT_BIP32_DERIVATION {
+				return dumpDerivation(e)
+			}
+			return ""
+		})
+	}
+}
+
+// dumpMap writes the entries of m. The describe function returns the
+// decoded form of an entry, or the empty string for the hex form.
+func dumpMap(w io.Writer, m Map, scope Scope, describe func(e Entry) string) {
+	for _, e := range m {
+		if len(e.Key) == 0 {
+			fmt.Fprintf(w, "  empty key: %x\n", e.Val)
+			continue
+		}
+		fmt.Fprintf(w, "  %s", KeyTypeName(scope, e.Key[0]))
+		if len(e.Key) > 1 {
+			fmt.Fprintf(w, " (key data %x)", e.Key[1:])
+		}
+		if d := describe(e); d != "" {
+			fmt.Fprintf(w, ": %s\n", d)
+		} else {
+			fmt.Fprintf(w, ": %x\n", e.Val)
+		}
+	}
+}
+
+// dumpDerivation returns the origin of a derivation entry.
+func dumpDerivation(e Entry) string {
+	d, err := DecodeDerivation(e)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("[%.8x%s]", d.MasterFingerprint, strings.TrimPrefix(FormatPath(d.Path), "m"))
+}
@@ -0,0 +1,161 @@
+package psbt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// This file implements operations on the signatures and final
+// scripts of PSBT inputs.
+
+// signatureKeyTypes are the input fields holding signatures
+// or data derived from signatures.
+var signatureKeyTypes = []byte{
+	PSBT_IN_PARTIAL_SIG,
+	PSBT_IN_FINAL_SCRIPTSIG,
+	PSBT_IN_FINAL_SCRIPTWITNESS,
+	PSBT_IN_TAP_KEY_SIG,
+	PSBT_IN_TAP_SCRIPT_SIG,
+}
+
+// ClearSignatures removes the partial signatures, taproot signatures
+// and final scripts of every input, leaving derivations, UTXOs and
+// other fields intact. Fields removed by a finalizer, such as the
+// derivations of a finalized input, are not restored.
+func (p *PSBT) ClearSignatures() {
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		// Clone to avoid modifying entries shared with other PSBTs.
+		in.Entries = slices.DeleteFunc(slices.Clone(in.Entries), func(e Entry) bool {
+			return len(e.Key) > 0 && slices.Contains(signatureKeyTypes, e.Key[0])
+		})
+		in.PartialSigs = nil
+		in.TapKeySig = nil
+		in.TapScriptSigs = nil
+		in.FinalScriptSig = nil
+		in.FinalScriptWitness = nil
+	}
+}
+
+// finalizedKeyTypes are the input fields removed by the finalizer
+// once the final scripts are set.
+var finalizedKeyTypes = []byte{
+	PSBT_IN_PARTIAL_SIG,
+	PSBT_IN_SIGHASH_TYPE,
+	PSBT_IN_REDEEM_SCRIPT,
+	PSBT_IN_WITNESS_SCRIPT,
+	PSBT_IN_BIP32_DERIVATION,
+}
+
+// ErrUnsupportedInput is returned by Finalize for inputs it
+// can't finalize.
+var ErrUnsupportedInput = errors.New("unsupported input type")
+
+// Finalize implements the BIP-174 finalizer for P2WSH and P2SH-P2WSH
+// multisig inputs. For every input not already finalized, it builds
+// the final witness from the partial signatures of the keys of the
+// witness script, in script order, and removes the fields no longer
+// needed. Finalize fails without modifying p if any input is of a
+// different type or lacks signatures.
+func (p *PSBT) Finalize() error {
+	inputs := slices.Clone(p.Inputs)
+	for i, in := range inputs {
+		if in.FinalScriptSig != nil || in.FinalScriptWitness != nil {
+			continue
+		}
+		in, err := finalizeMultisig(in)
+		if err != nil {
+			return fmt.Errorf("psbt: input %d: %w", i, err)
+		}
+		inputs[i] = in
+	}
+	p.Inputs = inputs
+	return nil
+}
+
+// finalizeMultisig finalizes a P2WSH or P2SH-P2WSH multisig input.
+func finalizeMultisig(in Input) (Input, error) {
+	ws := in.WitnessScript
+	if ws == nil || in.WitnessUTXO == nil {
+		return Input{}, ErrUnsupportedInput
+	}
+	threshold, pubkeys, err := ws.Multisig()
+	if err != nil {
+		return Input{}, fmt.Errorf("%w: %w", ErrUnsupportedInput, err)
+	}
+	spk := in.WitnessUTXO.ScriptPubKey
+	var scriptSig Script
+	switch {
+	case in.RedeemScript != nil:
+		if !bytes.Equal(in.RedeemScript, ws.P2WSH()) || !bytes.Equal(spk, in.RedeemScript.P2SH()) {
+			return Input{}, errors.New("redeem script doesn't match witness script and UTXO")
+		}
+		scriptSig = Script(nil).push(in.RedeemScript)
+	case !bytes.Equal(spk, ws.P2WSH()):
+		return Input{}, errors.New("witness script doesn't match UTXO")
+	}
+	var sigs [][]byte
+	for _, pk := range pubkeys {
+		if len(sigs) == threshold {
+			break
+		}
+		for _, s := range in.PartialSigs {
+			if bytes.Equal(s.PubKey, pk) {
+				sigs = append(sigs, s.Signature)
+				break
+			}
+		}
+	}
+	if len(sigs) < threshold {
+		return Input{}, fmt.Errorf("%d of %d signatures", len(sigs), threshold)
+	}
+	// The witness stack starts with an empty element for the extra
+	// item popped by OP_CHECKMULTISIG.
+	stack := append(append([][]byte{{}}, sigs...), ws)
+	witness := new(bytes.Buffer)
+	WriteVarInt(witness, uint64(len(stack)))
+	for _, item := range stack {
+		WriteVarInt(witness, uint64(len(item)))
+		witness.Write(item)
+	}
+	m := slices.DeleteFunc(slices.Clone(in.Entries), func(e Entry) bool {
+		return len(e.Key) > 0 && slices.Contains(finalizedKeyTypes, e.Key[0])
+	})
+	if scriptSig != nil {
+		m.Set(Entry{Key: []byte{PSBT_IN_FINAL_SCRIPTSIG}, Val: scriptSig})
+	}
+	m.Set(Entry{Key: []byte{PSBT_IN_FINAL_SCRIPTWITNESS}, Val: witness.Bytes()})
+	return decodeInput(m)
+}
+
+// ExtractTransaction returns the serialized network transaction of
+// a PSBT where every input is finalized. The transaction is
+// serialized with witnesses if any input has a final witness.
+func (p *PSBT) ExtractTransaction() ([]byte, error) {
+	if p.Global.Version >= 2 {
+		return nil, fmt.Errorf("psbt: extracting version %d transactions is not supported", p.Global.Version)
+	}
+	tx := p.Global.UnsignedTx
+	if len(p.Inputs) != len(tx.Inputs) {
+		return nil, fmt.Errorf("psbt: %d input maps for %d inputs", len(p.Inputs), len(tx.Inputs))
+	}
+	tx.Inputs = slices.Clone(tx.Inputs)
+	var witnesses [][]byte
+	for i, in := range p.Inputs {
+		if in.FinalScriptSig == nil && in.FinalScriptWitness == nil {
+			return nil, fmt.Errorf("psbt: input %d: not finalized", i)
+		}
+		tx.Inputs[i].ScriptSig = in.FinalScriptSig
+		if in.FinalScriptWitness != nil && witnesses == nil {
+			witnesses = make([][]byte, len(p.Inputs))
+		}
+	}
+	if witnesses != nil {
+		for i, in := range p.Inputs {
+			witnesses[i] = in.FinalScriptWitness
+		}
+	}
+	return tx.Encode(witnesses), nil
+}
@@ -0,0 +1,62 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// multisigInput returns a 2-of-2 P2WSH input with both partial
+// signatures.
+func multisigInput(t *testing.T) Input {
+	t.Helper()
+	pubkeys := [][]byte{
+		mustHex(t, "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"),
+		mustHex(t, "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"),
+	}
+	ws, err := MultiScript(2, pubkeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	utxo := binary.LittleEndian.AppendUint64(nil, 100000)
+	spk := ws.P2WSH()
+	utxo = append(utxo, byte(len(spk)))
+	utxo = append(utxo, spk...)
+	m := Map{
+		{Key: []byte{PSBT_IN_WITNESS_UTXO}, Val: utxo},
+		{Key: []byte{PSBT_IN_WITNESS_SCRIPT}, Val: ws},
+	}
+	for i, pk := range pubkeys {
+		sig := append(bytes.Repeat([]byte{byte(i + 1)}, 70), 0x01)
+		m = append(m, Entry{Key: append([]byte{PSBT_IN_PARTIAL_SIG}, pk...), Val: sig})
+	}
+	in, err := decodeInput(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return in
+}
+
+func TestFinalizeAfterClearSignatures(t *testing.T) {
+	p := PSBT{Inputs: []Input{multisigInput(t)}}
+	if err := p.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Inputs[0].FinalScriptWitness == nil {
+		t.Fatal("finalized input has no final witness")
+	}
+	p.ClearSignatures()
+	in := p.Inputs[0]
+	if in.FinalScriptSig != nil || in.FinalScriptWitness != nil {
+		t.Fatalf("final scripts remain after ClearSignatures: %x, %x", in.FinalScriptSig, in.FinalScriptWitness)
+	}
+	if _, ok := in.Entries.Get(PSBT_IN_FINAL_SCRIPTWITNESS); ok {
+		t.Fatal("final witness entry remains after ClearSignatures")
+	}
+	// The finalizer removed the witness script along with the
+	// signatures, so the input can no longer be finalized.
+	if err := p.Finalize(); !errors.Is(err, ErrUnsupportedInput) {
+		t.Fatalf("Finalize after ClearSignatures returned %v, want %v", err, ErrUnsupportedInput)
+	}
+}
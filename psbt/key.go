@@ -0,0 +1,257 @@
+package psbt
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/seedhammer/bip-serialized-descriptors/internal/base58"
+	"github.com/seedhammer/bip-serialized-descriptors/internal/ripemd160"
+	"github.com/seedhammer/bip-serialized-descriptors/internal/secp256k1"
+)
+
+// HardenedKeyStart is the offset of hardened derivation indices.
+const HardenedKeyStart = 0x80000000 // 2^31
+
+// Fingerprint returns the BIP-32 fingerprint of a compressed public key:
+// the first four bytes of its HASH160, interpreted big-endian.
+func Fingerprint(pubkey []byte) (uint32, error) {
+	if len(pubkey) != compressedPubKeyLen || (pubkey[0] != 0x02 && pubkey[0] != 0x03) {
+		return 0, errors.New("psbt: invalid compressed public key")
+	}
+	return binary.BigEndian.Uint32(hash160(pubkey)), nil
+}
+
+// hash160 returns RIPEMD160(SHA256(data)).
+func hash160(data []byte) []byte {
+	h := sha256.Sum256(data)
+	r := ripemd160.Sum(h[:])
+	return r[:]
+}
+
+// Equal reports whether k and other have the same master
+// fingerprint, derivation path and serialized key.
+func (k ExtendedKey) Equal(other ExtendedKey) bool {
+	return k.MasterFingerprint == other.MasterFingerprint &&
+		slices.Equal(k.Path, other.Path) &&
+		bytes.Equal(k.Key, other.Key)
+}
+
+// The accessors below slice the serialized key, which is laid out as
+// version (4 bytes), depth (1), parent fingerprint (4), child number (4),
+// chain code (32) and public key (33). They return zero values if Key
+// is not a 78 byte serialized key.
+
+// Depth returns the number of derivations from the master key.
+func (k ExtendedKey) Depth() uint8 {
+	if len(k.Key) != xpubLen {
+		return 0
+	}
+	return k.Key[4]
+}
+
+// ParentFingerprint returns the fingerprint of the parent key.
+func (k ExtendedKey) ParentFingerprint() uint32 {
+	if len(k.Key) != xpubLen {
+		return 0
+	}
+	return binary.BigEndian.Uint32(k.Key[5:9])
+}
+
+// ChildNumber returns the index of the key in its parent.
+func (k ExtendedKey) ChildNumber() uint32 {
+	if len(k.Key) != xpubLen {
+		return 0
+	}
+	return binary.BigEndian.Uint32(k.Key[9:13])
+}
+
+// ChainCode returns the 32 byte chain code.
+func (k ExtendedKey) ChainCode() []byte {
+	if len(k.Key) != xpubLen {
+		return nil
+	}
+	return k.Key[13:45]
+}
+
+// PubKey returns the 33 byte compressed public key.
+func (k ExtendedKey) PubKey() []byte {
+	if len(k.Key) != xpubLen {
+		return nil
+	}
+	return k.Key[45:78]
+}
+
+// ErrOriginMismatch is returned by Verify when the key origin
+// disagrees with the serialized key.
+var ErrOriginMismatch = errors.New("key origin mismatch")
+
+// Verify checks that the origin of k is consistent with the serialized
+// key: the depth must equal the path length and the child number must
+// equal the last path element. For keys at depth 1 the parent
+// fingerprint must equal the master fingerprint, and master keys must
+// have the fingerprint of their public key.
+func (k ExtendedKey) Verify() error {
+	if len(k.Key) != xpubLen {
+		return fmt.Errorf("psbt: invalid extended key length %d", len(k.Key))
+	}
+	if d := int(k.Depth()); d != len(k.Path) {
+		return fmt.Errorf("psbt: %w: depth %d, path %s", ErrOriginMismatch, d, FormatPath(k.Path))
+	}
+	switch len(k.Path) {
+	case 0:
+		fp, err := Fingerprint(k.PubKey())
+		if err != nil {
+			return err
+		}
+		if fp != k.MasterFingerprint {
+			return fmt.Errorf("psbt: %w: master key fingerprint %.8x, origin %.8x", ErrOriginMismatch, fp, k.MasterFingerprint)
+		}
+		return nil
+	case 1:
+		if pfp := k.ParentFingerprint(); pfp != k.MasterFingerprint {
+			return fmt.Errorf("psbt: %w: parent fingerprint %.8x, origin %.8x", ErrOriginMismatch, pfp, k.MasterFingerprint)
+		}
+	}
+	if c, last := k.ChildNumber(), k.Path[len(k.Path)-1]; c != last {
+		root := PathOpts{OmitRoot: true}
+		return fmt.Errorf("psbt: %w: child number %s, path element %s", ErrOriginMismatch,
+			PathString([]uint32{c}, root), PathString([]uint32{last}, root))
+	}
+	return nil
+}
+
+// ErrUnknownFingerprint is returned by MatchFingerprint for
+// fingerprints that match no expected fingerprint.
+var ErrUnknownFingerprint = errors.New("unknown master fingerprint")
+
+// ErrFingerprintByteOrder is returned by MatchFingerprint for
+// fingerprints that only match in reversed byte order.
+var ErrFingerprintByteOrder = errors.New("master fingerprint in reversed byte order")
+
+// MatchFingerprint returns the index of fp in expected. If fp is not
+// found, but its byte-swapped value is, the index of that value is
+// returned with ErrFingerprintByteOrder, which indicates an encoder
+// that wrote the fingerprint as a little endian integer. Otherwise,
+// ErrUnknownFingerprint is returned.
+func MatchFingerprint(fp uint32, expected []uint32) (int, error) {
+	if i := slices.Index(expected, fp); i != -1 {
+		return i, nil
+	}
+	swapped := bits.ReverseBytes32(fp)
+	if i := slices.Index(expected, swapped); i != -1 {
+		return i, fmt.Errorf("psbt: %w: %.8x is %.8x", ErrFingerprintByteOrder, fp, swapped)
+	}
+	return -1, fmt.Errorf("psbt: %w %.8x", ErrUnknownFingerprint, fp)
+}
+
+// String returns the base58check encoding of the key,
+// such as "xpub...".
+func (k ExtendedKey) String() string {
+	return base58.CheckEncode(k.Key)
+}
+
+// OriginString returns the key prefixed by its origin,
+// such as "[dc567276/48'/0'/0'/2']xpub...".
+func (k ExtendedKey) OriginString() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%.8x", k.MasterFingerprint)
+	b.WriteString(strings.TrimPrefix(FormatPath(k.Path), "m"))
+	b.WriteByte(']')
+	b.WriteString(k.String())
+	return b.String()
+}
+
+// Derive returns the non-hardened child of k at index, as specified by
+// the public parent to public child derivation of BIP-32. The child
+// has the version of k, and its path extends the path of k.
+func (k ExtendedKey) Derive(index uint32) (ExtendedKey, error) {
+	if index >= HardenedKeyStart {
+		return ExtendedKey{}, fmt.Errorf("psbt: hardened derivation %d from public key", index-HardenedKeyStart)
+	}
+	if len(k.Key) != xpubLen {
+		return ExtendedKey{}, fmt.Errorf("psbt: invalid extended key length %d", len(k.Key))
+	}
+	if k.Depth() == 0xff {
+		return ExtendedKey{}, errors.New("psbt: maximum depth exceeded")
+	}
+	parent, err := secp256k1.ParseCompressed(k.PubKey())
+	if err != nil {
+		return ExtendedKey{}, fmt.Errorf("psbt: %w", err)
+	}
+	mac := hmac.New(sha512.New, k.ChainCode())
+	mac.Write(k.PubKey())
+	mac.Write(binary.BigEndian.AppendUint32(nil, index))
+	I := mac.Sum(nil)
+	il := new(big.Int).SetBytes(I[:32])
+	if il.Cmp(secp256k1.N) >= 0 {
+		return ExtendedKey{}, fmt.Errorf("psbt: invalid child %d", index)
+	}
+	child := secp256k1.Add(secp256k1.ScalarBaseMult(il), parent)
+	if child.IsInfinity() {
+		return ExtendedKey{}, fmt.Errorf("psbt: invalid child %d", index)
+	}
+	key := make([]byte, 0, xpubLen)
+	key = append(key, k.Key[:4]...)
+	key = append(key, k.Depth()+1)
+	key = append(key, hash160(k.PubKey())[:4]...)
+	key = binary.BigEndian.AppendUint32(key, index)
+	key = append(key, I[32:]...)
+	key = append(key, child.Compressed()...)
+	return ExtendedKey{
+		MasterFingerprint: k.MasterFingerprint,
+		Path:              append(slices.Clip(k.Path), index),
+		Key:               key,
+	}, nil
+}
+
+// ParseExtendedKey parses a base58check encoded extended key, optionally
+// prefixed by its origin as returned by OriginString. Both ' and h are
+// accepted as hardened markers. The version bytes must be one of the
+// standard or SLIP-132 public key versions, such as xpub or zpub.
+func ParseExtendedKey(s string) (ExtendedKey, error) {
+	var k ExtendedKey
+	if strings.HasPrefix(s, "[") {
+		origin, rest, ok := strings.Cut(s[1:], "]")
+		if !ok {
+			return ExtendedKey{}, errors.New("psbt: missing ']' in key origin")
+		}
+		fp, path, _ := strings.Cut(origin, "/")
+		if len(fp) != 8 {
+			return ExtendedKey{}, fmt.Errorf("psbt: invalid fingerprint %q", fp)
+		}
+		mfp, err := strconv.ParseUint(fp, 16, 32)
+		if err != nil {
+			return ExtendedKey{}, fmt.Errorf("psbt: invalid fingerprint %q", fp)
+		}
+		k.MasterFingerprint = uint32(mfp)
+		if path != "" {
+			k.Path, err = parsePathElements(strings.Split(path, "/"))
+			if err != nil {
+				return ExtendedKey{}, fmt.Errorf("psbt: invalid key origin: %w", err)
+			}
+		}
+		s = rest
+	}
+	key, err := base58.CheckDecode(s)
+	if err != nil {
+		return ExtendedKey{}, fmt.Errorf("psbt: invalid extended key: %w", err)
+	}
+	if len(key) != xpubLen {
+		return ExtendedKey{}, fmt.Errorf("psbt: invalid extended key length %d", len(key))
+	}
+	k.Key = key
+	if _, err := k.version(); err != nil {
+		return ExtendedKey{}, err
+	}
+	return k, nil
+}
@@ -0,0 +1,128 @@
+package psbt
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// The master key of BIP-32 test vector 1 has fingerprint 3442193e.
+const (
+	testMasterPubKey      = "0339a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2"
+	testMasterFingerprint = 0x3442193e
+)
+
+func TestFingerprint(t *testing.T) {
+	fp, err := Fingerprint(mustHex(t, testMasterPubKey))
+	if err != nil || fp != testMasterFingerprint {
+		t.Errorf("Fingerprint = %.8x, %v, want %.8x", fp, err, testMasterFingerprint)
+	}
+}
+
+func TestFingerprintByteOrder(t *testing.T) {
+	k, err := ParseExtendedKey(testCosigners[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fingerprint is big endian, the path elements little endian.
+	e := Entry{
+		Key: append([]byte{PSBT_GLOBAL_XPUB}, k.Key...),
+		Val: mustHex(t, "3442193e"+"2c000080"+"00000080"),
+	}
+	got, err := DecodePSBTXpub(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MasterFingerprint != testMasterFingerprint {
+		t.Errorf("fingerprint %.8x, want %.8x", got.MasterFingerprint, testMasterFingerprint)
+	}
+	if want := []uint32{HardenedKeyStart + 44, HardenedKeyStart}; !slices.Equal(got.Path, want) {
+		t.Errorf("path %v, want %v", got.Path, want)
+	}
+
+	expected := []uint32{0xdc567276, testMasterFingerprint}
+	if i, err := MatchFingerprint(testMasterFingerprint, expected); i != 1 || err != nil {
+		t.Errorf("MatchFingerprint = %d, %v, want 1", i, err)
+	}
+	// A fingerprint written as a little endian integer.
+	if i, err := MatchFingerprint(0x3e194234, expected); i != 1 || !errors.Is(err, ErrFingerprintByteOrder) {
+		t.Errorf("MatchFingerprint of reversed fingerprint = %d, %v, want 1, %v", i, err, ErrFingerprintByteOrder)
+	}
+	if i, err := MatchFingerprint(0x01020304, expected); i != -1 || !errors.Is(err, ErrUnknownFingerprint) {
+		t.Errorf("MatchFingerprint of unknown fingerprint = %d, %v, want -1, %v", i, err, ErrUnknownFingerprint)
+	}
+}
+
+// The vectors are the public derivations of BIP-32 test vectors 1
+// and 2.
+func TestDerive(t *testing.T) {
+	tests := []struct {
+		parent string
+		index  uint32
+		child  string
+	}{
+		// Test vector 1, m/0H to m/0H/1.
+		{
+			"xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw",
+			1,
+			"xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+		},
+		// Test vector 1, m/0H/1/2H to m/0H/1/2H/2.
+		{
+			"xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5",
+			2,
+			"xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+		},
+		// Test vector 1, m/0H/1/2H/2 to m/0H/1/2H/2/1000000000.
+		{
+			"xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+			1000000000,
+			"xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy",
+		},
+		// Test vector 2, m to m/0.
+		{
+			"xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB",
+			0,
+			"xpub69H7F5d8KSRgmmdJg2KhpAK8SR3DjMwAdkxj3ZuxV27CprR9LgpeyGmXUbC6wb7ERfvrnKZjXoUmmDznezpbZb7ap6r1D3tgFxHmwMkQTPH",
+		},
+		// Test vector 2, m/0/2147483647H to m/0/2147483647H/1.
+		{
+			"xpub6ASAVgeehLbnwdqV6UKMHVzgqAG8Gr6riv3Fxxpj8ksbH9ebxaEyBLZ85ySDhKiLDBrQSARLq1uNRts8RuJiHjaDMBU4Zn9h8LZNnBC5y4a",
+			1,
+			"xpub6DF8uhdarytz3FWdA8TvFSvvAh8dP3283MY7p2V4SeE2wyWmG5mg5EwVvmdMVCQcoNJxGoWaU9DCWh89LojfZ537wTfunKau47EL2dhHKon",
+		},
+		// Test vector 2, m/0/2147483647H/1/2147483646H to
+		// m/0/2147483647H/1/2147483646H/2.
+		{
+			"xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL",
+			2,
+			"xpub6FnCn6nSzZAw5Tw7cgR9bi15UV96gLZhjDstkXXxvCLsUXBGXPdSnLFbdpq8p9HmGsApME5hQTZ3emM2rnY5agb9rXpVGyy3bdW6EEgAtqt",
+		},
+	}
+	for _, test := range tests {
+		parent, err := ParseExtendedKey(test.parent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		child, err := parent.Derive(test.index)
+		if err != nil {
+			t.Errorf("%s/%d: %v", test.parent, test.index, err)
+			continue
+		}
+		if got := child.String(); got != test.child {
+			t.Errorf("%s/%d = %s, want %s", test.parent, test.index, got, test.child)
+		}
+	}
+}
+
+// Every derivation of test vector 3 is hardened, and can't be
+// performed from the public master key.
+func TestDeriveHardened(t *testing.T) {
+	k, err := ParseExtendedKey("xpub661MyMwAqRbcEZVB4dScxMAdx6d4nFc9nvyvH3v4gJL378CSRZiYmhRoP7mBy6gSPSCYk6SzXPTf3ND1cZAceL7SfJ1Z3GC8vBgp2epUt13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child, err := k.Derive(HardenedKeyStart); err == nil {
+		t.Errorf("Derive(0H) = %s, want error", child)
+	}
+}
@@ -0,0 +1,90 @@
+package psbt
+
+import "fmt"
+
+// Scope is the kind of map a field type belongs to.
+type Scope int
+
+const (
+	ScopeGlobal Scope = iota
+	ScopeInput
+	ScopeOutput
+)
+
+func (s Scope) String() string {
+	switch s {
+	case ScopeGlobal:
+		return "global"
+	case ScopeInput:
+		return "input"
+	case ScopeOutput:
+		return "output"
+	default:
+		return fmt.Sprintf("Scope(%d)", int(s))
+	}
+}
+
+// keyTypeNames holds the names of the BIP-174 and BIP-370 field
+// types of each scope, including those not decoded by this package.
+var keyTypeNames = map[Scope]map[byte]string{
+	ScopeGlobal: {
+		PSBT_GLOBAL_UNSIGNED_TX:       "PSBT_GLOBAL_UNSIGNED_TX",
+		PSBT_GLOBAL_XPUB:              "PSBT_GLOBAL_XPUB",
+		PSBT_GLOBAL_TX_VERSION:        "PSBT_GLOBAL_TX_VERSION",
+		PSBT_GLOBAL_FALLBACK_LOCKTIME: "PSBT_GLOBAL_FALLBACK_LOCKTIME",
+		PSBT_GLOBAL_INPUT_COUNT:       "PSBT_GLOBAL_INPUT_COUNT",
+		PSBT_GLOBAL_OUTPUT_COUNT:      "PSBT_GLOBAL_OUTPUT_COUNT",
+		0x06:                          "PSBT_GLOBAL_TX_MODIFIABLE",
+		PSBT_GLOBAL_VERSION:           "PSBT_GLOBAL_VERSION",
+		PSBT_PROPRIETARY:              "PSBT_GLOBAL_PROPRIETARY",
+	},
+	ScopeInput: {
+		PSBT_IN_NON_WITNESS_UTXO:     "PSBT_IN_NON_WITNESS_UTXO",
+		PSBT_IN_WITNESS_UTXO:         "PSBT_IN_WITNESS_UTXO",
+		PSBT_IN_PARTIAL_SIG:          "PSBT_IN_PARTIAL_SIG",
+		PSBT_IN_SIGHASH_TYPE:         "PSBT_IN_SIGHASH_TYPE",
+		PSBT_IN_REDEEM_SCRIPT:        "PSBT_IN_REDEEM_SCRIPT",
+		PSBT_IN_WITNESS_SCRIPT:       "PSBT_IN_WITNESS_SCRIPT",
+		PSBT_IN_BIP32_DERIVATION:     "PSBT_IN_BIP32_DERIVATION",
+		PSBT_IN_FINAL_SCRIPTSIG:      "PSBT_IN_FINAL_SCRIPTSIG",
+		PSBT_IN_FINAL_SCRIPTWITNESS:  "PSBT_IN_FINAL_SCRIPTWITNESS",
+		0x09:                         "PSBT_IN_POR_COMMITMENT",
+		0x0a:                         "PSBT_IN_RIPEMD160",
+		0x0b:                         "PSBT_IN_SHA256",
+		0x0c:                         "PSBT_IN_HASH160",
+		0x0d:                         "PSBT_IN_HASH256",
+		0x0e:                         "PSBT_IN_PREVIOUS_TXID",
+		0x0f:                         "PSBT_IN_OUTPUT_INDEX",
+		0x10:                         "PSBT_IN_SEQUENCE",
+		0x11:                         "PSBT_IN_REQUIRED_TIME_LOCKTIME",
+		0x12:                         "PSBT_IN_REQUIRED_HEIGHT_LOCKTIME",
+		PSBT_IN_TAP_KEY_SIG:          "PSBT_IN_TAP_KEY_SIG",
+		PSBT_IN_TAP_SCRIPT_SIG:       "PSBT_IN_TAP_SCRIPT_SIG",
+		PSBT_IN_TAP_LEAF_SCRIPT:      "PSBT_IN_TAP_LEAF_SCRIPT",
+		PSBT_IN_TAP_BIP32_DERIVATION: "PSBT_IN_TAP_BIP32_DERIVATION",
+		PSBT_IN_TAP_INTERNAL_KEY:     "PSBT_IN_TAP_INTERNAL_KEY",
+		0x18:                         "PSBT_IN_TAP_MERKLE_ROOT",
+		PSBT_PROPRIETARY:             "PSBT_IN_PROPRIETARY",
+	},
+	ScopeOutput: {
+		PSBT_OUT_REDEEM_SCRIPT:    "PSBT_OUT_REDEEM_SCRIPT",
+		PSBT_OUT_WITNESS_SCRIPT:   "PSBT_OUT_WITNESS_SCRIPT",
+		PSBT_OUT_BIP32_DERIVATION: "PSBT_OUT_BIP32_DERIVATION",
+		0x03:                      "PSBT_OUT_AMOUNT",
+		0x04:                      "PSBT_OUT_SCRIPT",
+		0x05:                      "PSBT_OUT_TAP_INTERNAL_KEY",
+		0x06:                      "PSBT_OUT_TAP_TREE",
+		0x07:                      "PSBT_OUT_TAP_BIP32_DERIVATION",
+		PSBT_PROPRIETARY:          "PSBT_OUT_PROPRIETARY",
+	},
+}
+
+// KeyTypeName returns the name of a field type in the given scope,
+// such as "PSBT_IN_WITNESS_UTXO", or "unknown(0xNN)" for unknown
+// field types.
+func KeyTypeName(scope Scope, keyType byte) string {
+	if name, ok := keyTypeNames[scope][keyType]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%#.2x)", keyType)
+}
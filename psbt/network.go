@@ -0,0 +1,152 @@
+package psbt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Network is the Bitcoin network an extended key belongs to.
+type Network int
+
+const (
+	Mainnet Network = iota
+	Testnet
+	Regtest
+	Signet
+)
+
+func (n Network) String() string {
+	switch n {
+	case Mainnet:
+		return "mainnet"
+	case Testnet:
+		return "testnet"
+	case Regtest:
+		return "regtest"
+	case Signet:
+		return "signet"
+	default:
+		return fmt.Sprintf("Network(%d)", int(n))
+	}
+}
+
+// ScriptType is the output script type implied by extended key
+// version bytes or by a descriptor.
+type ScriptType int
+
+const (
+	// ScriptAny is the script type of standard xpub and tpub keys,
+	// whose version bytes don't imply a particular script.
+	ScriptAny ScriptType = iota
+	P2PKH
+	P2SHP2WPKH
+	P2WPKH
+	P2SH
+	P2SHP2WSH
+	P2WSH
+	P2TR
+)
+
+func (t ScriptType) String() string {
+	switch t {
+	case ScriptAny:
+		return "any"
+	case P2PKH:
+		return "p2pkh"
+	case P2SHP2WPKH:
+		return "p2sh-p2wpkh"
+	case P2WPKH:
+		return "p2wpkh"
+	case P2SH:
+		return "p2sh"
+	case P2SHP2WSH:
+		return "p2sh-p2wsh"
+	case P2WSH:
+		return "p2wsh"
+	case P2TR:
+		return "p2tr"
+	default:
+		return fmt.Sprintf("ScriptType(%d)", int(t))
+	}
+}
+
+// keyVersion describes the meaning of extended public key version bytes.
+type keyVersion struct {
+	network Network
+	script  ScriptType
+}
+
+// keyVersions maps public key version bytes to their meaning, as
+// registered in SLIP-132. Testnet, regtest and signet share version
+// bytes and are all reported as Testnet.
+var keyVersions = map[uint32]keyVersion{
+	0x0488b21e: {Mainnet, ScriptAny},  // xpub
+	0x049d7cb2: {Mainnet, P2SHP2WPKH}, // ypub
+	0x04b24746: {Mainnet, P2WPKH},     // zpub
+	0x0295b43f: {Mainnet, P2SHP2WSH},  // Ypub
+	0x02aa7ed3: {Mainnet, P2WSH},      // Zpub
+	0x043587cf: {Testnet, ScriptAny},  // tpub
+	0x044a5262: {Testnet, P2SHP2WPKH}, // upub
+	0x045f1cf6: {Testnet, P2WPKH},     // vpub
+	0x024289ef: {Testnet, P2SHP2WSH},  // Upub
+	0x02575483: {Testnet, P2WSH},      // Vpub
+}
+
+// ErrUnknownKeyVersion is returned for extended keys with
+// unrecognized version bytes.
+var ErrUnknownKeyVersion = errors.New("unknown extended key version")
+
+func (k ExtendedKey) version() (keyVersion, error) {
+	if len(k.Key) < 4 {
+		return keyVersion{}, fmt.Errorf("psbt: %w", ErrTruncated)
+	}
+	v := binary.BigEndian.Uint32(k.Key)
+	kv, ok := keyVersions[v]
+	if !ok {
+		return keyVersion{}, fmt.Errorf("psbt: %w: %#.8x", ErrUnknownKeyVersion, v)
+	}
+	return kv, nil
+}
+
+// Network returns the network encoded in the key version bytes. Because
+// the test networks share version bytes, Regtest and Signet keys are
+// reported as Testnet.
+func (k ExtendedKey) Network() (Network, error) {
+	kv, err := k.version()
+	return kv.network, err
+}
+
+// ScriptType returns the script type encoded in the key version bytes,
+// or ScriptAny for standard xpub and tpub keys.
+func (k ExtendedKey) ScriptType() (ScriptType, error) {
+	kv, err := k.version()
+	return kv.script, err
+}
+
+// WithScriptType returns k with the SLIP-132 version bytes for its
+// network and the script type t, such as zpub for P2WPKH on mainnet.
+// Script types without SLIP-132 version bytes, such as P2PKH and
+// P2TR, result in the standard xpub or tpub version.
+func (k ExtendedKey) WithScriptType(t ScriptType) (ExtendedKey, error) {
+	kv, err := k.version()
+	if err != nil {
+		return ExtendedKey{}, err
+	}
+	want := keyVersion{network: kv.network, script: t}
+	switch t {
+	case P2SHP2WPKH, P2WPKH, P2SHP2WSH, P2WSH:
+	default:
+		want.script = ScriptAny
+	}
+	for v, kv := range keyVersions {
+		if kv == want {
+			key := slices.Clone(k.Key)
+			binary.BigEndian.PutUint32(key, v)
+			k.Key = key
+			return k, nil
+		}
+	}
+	return ExtendedKey{}, fmt.Errorf("psbt: no version bytes for %s %s keys", kv.network, t)
+}
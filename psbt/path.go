@@ -0,0 +1,91 @@
+package psbt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FormatPath formats a derivation path such as "m/48'/0'/0'/2'".
+// Indices at or above HardenedKeyStart are marked hardened with '.
+// It is equivalent to PathString with the zero PathOpts.
+func FormatPath(path []uint32) string {
+	return PathString(path, PathOpts{})
+}
+
+// PathOpts controls the notation of PathString.
+type PathOpts struct {
+	// HardenedH marks hardened indices with h instead of '.
+	HardenedH bool
+	// OmitRoot omits the leading "m/", or "m" for the empty path.
+	OmitRoot bool
+}
+
+// PathString formats a derivation path in the notation selected by
+// opts, such as "m/48'/0'/0'/2'" or "48h/0h/0h/2h". Indices at or above
+// HardenedKeyStart are marked hardened. The empty path is formatted as
+// "m", or as the empty string if opts.OmitRoot is set.
+func PathString(path []uint32, opts PathOpts) string {
+	marker := "'"
+	if opts.HardenedH {
+		marker = "h"
+	}
+	var b strings.Builder
+	if !opts.OmitRoot {
+		b.WriteByte('m')
+	}
+	for i, p := range path {
+		if i > 0 || !opts.OmitRoot {
+			b.WriteByte('/')
+		}
+		if p >= HardenedKeyStart {
+			fmt.Fprintf(&b, "%d%s", p-HardenedKeyStart, marker)
+		} else {
+			fmt.Fprintf(&b, "%d", p)
+		}
+	}
+	return b.String()
+}
+
+// ParsePath parses a derivation path in the form returned by FormatPath.
+// Both ' and h are accepted as hardened markers, and the leading "m/" is
+// optional.
+func ParsePath(s string) ([]uint32, error) {
+	if s == "m" {
+		return nil, nil
+	}
+	s = strings.TrimPrefix(s, "m/")
+	if s == "" {
+		return nil, errors.New("psbt: empty derivation path")
+	}
+	path, err := parsePathElements(strings.Split(s, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("psbt: %w", err)
+	}
+	return path, nil
+}
+
+// parsePathElements parses derivation path elements such as
+// "48'", "0h" or "2".
+func parsePathElements(elems []string) ([]uint32, error) {
+	var path []uint32
+	for _, e := range elems {
+		offset := uint32(0)
+		if n, ok := strings.CutSuffix(e, "'"); ok {
+			e, offset = n, HardenedKeyStart
+		} else if n, ok := strings.CutSuffix(e, "h"); ok {
+			e, offset = n, HardenedKeyStart
+		}
+		// Reject signs and other forms accepted by ParseUint.
+		if e == "" || strings.TrimLeft(e, "0123456789") != "" {
+			return nil, fmt.Errorf("invalid path element %q", e)
+		}
+		idx, err := strconv.ParseUint(e, 10, 32)
+		if err != nil || idx >= HardenedKeyStart {
+			return nil, fmt.Errorf("path element %q out of range", e)
+		}
+		path = append(path, uint32(idx)+offset)
+	}
+	return path, nil
+}
@@ -0,0 +1,74 @@
+package psbt
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// PSBT_PROPRIETARY is the key type reserved for proprietary
+// use in every map.
+const PSBT_PROPRIETARY = 0xfc
+
+// Proprietary is a proprietary entry, whose key is the key type
+// followed by a length prefixed identifier, a subtype and a subkey.
+type Proprietary struct {
+	// Identifier names the owner of the entry, such as
+	// a wallet vendor.
+	Identifier []byte
+	Subtype    uint64
+	Subkey     []byte
+	Value      []byte
+}
+
+// DecodeProprietary decodes a PSBT_PROPRIETARY entry.
+func DecodeProprietary(e Entry) (Proprietary, error) {
+	if len(e.Key) == 0 || e.Key[0] != PSBT_PROPRIETARY {
+		return Proprietary{}, fmt.Errorf("not a proprietary key: %x", e.Key)
+	}
+	key := e.Key[1:]
+	idLen, n, err := decodeVarInt(key)
+	if err != nil {
+		return Proprietary{}, fmt.Errorf("proprietary identifier length: %w", err)
+	}
+	key = key[n:]
+	if idLen > uint64(len(key)) {
+		return Proprietary{}, fmt.Errorf("proprietary identifier: %w", ErrTruncated)
+	}
+	id := key[:idLen]
+	key = key[idLen:]
+	subtype, n, err := decodeVarInt(key)
+	if err != nil {
+		return Proprietary{}, fmt.Errorf("proprietary subtype: %w", err)
+	}
+	return Proprietary{
+		Identifier: id,
+		Subtype:    subtype,
+		Subkey:     key[n:],
+		Value:      e.Val,
+	}, nil
+}
+
+// Entry encodes p as a map entry.
+func (p Proprietary) Entry() Entry {
+	key := new(bytes.Buffer)
+	key.WriteByte(PSBT_PROPRIETARY)
+	WriteVarInt(key, uint64(len(p.Identifier)))
+	key.Write(p.Identifier)
+	WriteVarInt(key, p.Subtype)
+	key.Write(p.Subkey)
+	return Entry{Key: key.Bytes(), Val: p.Value}
+}
+
+// Proprietary returns the decoded proprietary entries of m. The
+// entries themselves are unchanged and round-trip exactly.
+func (m Map) Proprietary() ([]Proprietary, error) {
+	var props []Proprietary
+	for _, e := range m.GetAll(PSBT_PROPRIETARY) {
+		p, err := DecodeProprietary(e)
+		if err != nil {
+			return nil, fmt.Errorf("psbt: %w", err)
+		}
+		props = append(props, p)
+	}
+	return props, nil
+}
@@ -6,94 +6,826 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"slices"
 )
 
 // This file implements BIP-174 decoding and encoding and
 // includes a very basic PSBT decoder for verification.
 
+var (
+	// ErrInvalidMagic is returned when the input doesn't start
+	// with the expected magic bytes.
+	ErrInvalidMagic = errors.New("invalid magic")
+	// ErrTruncated is returned when the input ends prematurely.
+	ErrTruncated = errors.New("truncated data")
+	// ErrDuplicateKey is returned when a key appears more than
+	// once in a map.
+	ErrDuplicateKey = errors.New("duplicate key")
+	// ErrNonCanonicalVarInt is returned when a variable length
+	// integer is not encoded in its shortest form.
+	ErrNonCanonicalVarInt = errors.New("non-canonical variable length integer")
+	// ErrTrailingData is returned when input remains after
+	// the final map.
+	ErrTrailingData = errors.New("trailing data")
+	// ErrTooLarge is returned when decoding exceeds a size limit.
+	ErrTooLarge = errors.New("size limit exceeded")
+	// ErrUnsupportedVersion is returned for PSBT versions newer
+	// than MaxVersion.
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	// ErrSignedTx is returned when the unsigned transaction
+	// has a scriptSig or witness data.
+	ErrSignedTx = errors.New("unsigned transaction has signature data")
+)
+
+// ExtendedKey is a BIP-32 extended public key with its origin.
 type ExtendedKey struct {
+	// MasterFingerprint is the fingerprint of the master key, the
+	// first 4 bytes of its hash160 read as a big endian integer, so
+	// fingerprint d34db33f is 0xd34db33f.
 	MasterFingerprint uint32
-	Path              []uint32
-	Key               []byte
+	// Path is the derivation path from the master key. Hardened
+	// indices include the HardenedKeyStart offset, so 48' is
+	// represented as HardenedKeyStart + 48.
+	Path []uint32
+	// Key is the 78 byte serialized extended key.
+	Key []byte
 }
 
+// DecodePSBTXpub decodes a PSBT_GLOBAL_XPUB entry, whose key holds
+// the 78 byte serialized extended key after the key type.
 func DecodePSBTXpub(e Entry) (ExtendedKey, error) {
-	val := e.Val
-	if len(val) < 4 || len(val)%4 != 0 {
-		return ExtendedKey{}, io.ErrUnexpectedEOF
+	key := keyData(e)
+	if len(key) != xpubLen {
+		return ExtendedKey{}, fmt.Errorf("invalid extended key length %d", len(key))
+	}
+	mfp, path, err := decodeOrigin(e.Val)
+	if err != nil {
+		return ExtendedKey{}, err
+	}
+	return ExtendedKey{
+		MasterFingerprint: mfp,
+		Path:              path,
+		Key:               key,
+	}, nil
+}
+
+// Derivation is the BIP-32 origin of a public key.
+type Derivation struct {
+	PubKey []byte
+	ExtendedKey
+}
+
+// compressedPubKeyLen is the length of a compressed public key.
+const compressedPubKeyLen = 33
+
+// DecodeDerivation decodes a PSBT_IN_BIP32_DERIVATION or
+// PSBT_OUT_BIP32_DERIVATION entry.
+func DecodeDerivation(e Entry) (Derivation, error) {
+	pub := keyData(e)
+	if len(pub) != compressedPubKeyLen {
+		return Derivation{}, fmt.Errorf("invalid public key length %d", len(pub))
+	}
+	mfp, path, err := decodeOrigin(e.Val)
+	if err != nil {
+		return Derivation{}, err
 	}
-	k := ExtendedKey{
-		Key: e.Key[1:],
+	return Derivation{
+		PubKey: pub,
+		ExtendedKey: ExtendedKey{
+			MasterFingerprint: mfp,
+			Path:              path,
+		},
+	}, nil
+}
+
+// decodeOrigin decodes a master key fingerprint followed
+// by a derivation path. As specified by BIP-174, the fingerprint
+// is in its natural byte order, which is big endian when read as
+// an integer, while the path elements are 32-bit little endian.
+// See MatchFingerprint for detecting fingerprints written in the
+// wrong byte order.
+func decodeOrigin(val []byte) (uint32, []uint32, error) {
+	if len(val) < 4 || len(val)%4 != 0 {
+		return 0, nil, ErrTruncated
 	}
-	k.MasterFingerprint = binary.BigEndian.Uint32(val)
+	mfp := binary.BigEndian.Uint32(val)
 	val = val[4:]
+	var path []uint32
 	for len(val) > 0 {
 		p := binary.LittleEndian.Uint32(val)
 		val = val[4:]
-		k.Path = append(k.Path, p)
+		path = append(path, p)
+	}
+	return mfp, path, nil
+}
+
+const (
+	// The field type for the unsigned transaction in the global map.
+	PSBT_GLOBAL_UNSIGNED_TX = 0x00
+	// The field type for an extended public key in the global map.
+	PSBT_GLOBAL_XPUB = 0x01
+	// The field types for the BIP-370 transaction version, fallback
+	// locktime, and number of inputs and outputs.
+	PSBT_GLOBAL_TX_VERSION        = 0x02
+	PSBT_GLOBAL_FALLBACK_LOCKTIME = 0x03
+	PSBT_GLOBAL_INPUT_COUNT       = 0x04
+	PSBT_GLOBAL_OUTPUT_COUNT      = 0x05
+	// The field type for the PSBT version number.
+	PSBT_GLOBAL_VERSION = 0xfb
+)
+
+const (
+	// The field type for the full transaction spent by an input.
+	PSBT_IN_NON_WITNESS_UTXO = 0x00
+	// The field type for the output spent by a segwit input.
+	PSBT_IN_WITNESS_UTXO = 0x01
+	// The field type for a signature by one of the input keys.
+	PSBT_IN_PARTIAL_SIG = 0x02
+	// The field type for the sighash type of signatures for an input.
+	PSBT_IN_SIGHASH_TYPE = 0x03
+	// The field types for the P2SH redeem script and the P2WSH
+	// witness script of an input.
+	PSBT_IN_REDEEM_SCRIPT  = 0x04
+	PSBT_IN_WITNESS_SCRIPT = 0x05
+	// The field type for the derivation of a public key used by an input.
+	PSBT_IN_BIP32_DERIVATION = 0x06
+	// The field types for the finalized scriptSig and scriptWitness.
+	PSBT_IN_FINAL_SCRIPTSIG     = 0x07
+	PSBT_IN_FINAL_SCRIPTWITNESS = 0x08
+	// The BIP-371 field types for taproot key path and script path
+	// signatures, leaf scripts, key derivations and the internal key.
+	PSBT_IN_TAP_KEY_SIG          = 0x13
+	PSBT_IN_TAP_SCRIPT_SIG       = 0x14
+	PSBT_IN_TAP_LEAF_SCRIPT      = 0x15
+	PSBT_IN_TAP_BIP32_DERIVATION = 0x16
+	PSBT_IN_TAP_INTERNAL_KEY     = 0x17
+)
+
+const (
+	// The field type for the P2SH redeem script of an output.
+	PSBT_OUT_REDEEM_SCRIPT = 0x00
+	// The field type for the P2WSH witness script of an output.
+	PSBT_OUT_WITNESS_SCRIPT = 0x01
+	// The field type for the BIP-32 derivation of an output key.
+	PSBT_OUT_BIP32_DERIVATION = 0x02
+)
+
+// MaxVersion is the highest PSBT version understood by Decode.
+const MaxVersion = 2
+
+// xpubLen is the length of a serialized extended public key.
+const xpubLen = 78
+
+// PSBT is a decoded partially signed transaction. Input and output
+// maps are kept in the order they appear.
+type PSBT struct {
+	Global  Global
+	Inputs  []Input
+	Outputs []Output
+}
+
+// Global is the decoded global map of a PSBT.
+type Global struct {
+	// Entries holds every global entry in the order they appear.
+	Entries Map
+	// UnsignedTx is the unsigned transaction of a version 0 PSBT.
+	UnsignedTx Transaction
+	// Xpubs holds the keys from PSBT_GLOBAL_XPUB entries.
+	Xpubs []ExtendedKey
+	// Version is the PSBT version, 0 if PSBT_GLOBAL_VERSION is absent.
+	Version uint32
+
+	// The BIP-370 fields of a version 2 PSBT.
+	TxVersion        uint32
+	FallbackLocktime uint32
+	InputCount       int
+	OutputCount      int
+}
+
+// counts returns the number of input and output maps that
+// follow the global map.
+func (g Global) counts() (int, int) {
+	if g.Version >= 2 {
+		return g.InputCount, g.OutputCount
+	}
+	return len(g.UnsignedTx.Inputs), len(g.UnsignedTx.Outputs)
+}
+
+// Input is a decoded input map of a PSBT.
+type Input struct {
+	// Entries holds every input entry in the order they appear.
+	Entries Map
+	// NonWitnessUTXO is the serialized transaction spent
+	// by the input.
+	NonWitnessUTXO []byte
+	// WitnessUTXO is the transaction output spent by the input.
+	WitnessUTXO *TxOut
+	// Derivations holds the PSBT_IN_BIP32_DERIVATION entries.
+	Derivations []Derivation
+	// PartialSigs holds the PSBT_IN_PARTIAL_SIG entries.
+	PartialSigs []PartialSig
+	// SighashType is the PSBT_IN_SIGHASH_TYPE of the input. If
+	// absent, it is SighashDefault for taproot inputs and SighashAll
+	// for others. HasSighashType reports whether the field is present.
+	SighashType    SighashType
+	HasSighashType bool
+	// RedeemScript is the P2SH redeem script of the input.
+	RedeemScript Script
+	// WitnessScript is the P2WSH witness script of the input.
+	WitnessScript Script
+	// FinalScriptSig and FinalScriptWitness are the scripts of a
+	// finalized input. FinalScriptWitness is serialized as in a
+	// transaction witness.
+	FinalScriptSig     []byte
+	FinalScriptWitness []byte
+
+	// The BIP-371 taproot fields.
+	TapKeySig      []byte
+	TapScriptSigs  []TapScriptSig
+	TapLeafScripts []TapLeafScript
+	TapDerivations []TapDerivation
+	TapInternalKey []byte
+}
+
+// Output is a decoded output map of a PSBT.
+type Output struct {
+	// Entries holds every output entry in the order they appear.
+	Entries Map
+	// RedeemScript is the P2SH redeem script of the output.
+	RedeemScript Script
+	// WitnessScript is the P2WSH witness script of the output.
+	WitnessScript Script
+	// Derivations holds the PSBT_OUT_BIP32_DERIVATION entries.
+	Derivations []Derivation
+}
+
+// PartialSig is a signature for an input.
+type PartialSig struct {
+	PubKey []byte
+	// Signature is the DER encoded signature followed
+	// by the sighash type byte.
+	Signature []byte
+}
+
+// Map is a BIP-174 key-value map.
+type Map []Entry
+
+// Get returns the first entry of m with the given key type.
+func (m Map) Get(keyType byte) (Entry, bool) {
+	for _, e := range m {
+		if len(e.Key) > 0 && e.Key[0] == keyType {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// GetAll returns the entries of m with the given key type, in order.
+func (m Map) GetAll(keyType byte) []Entry {
+	var entries []Entry
+	for _, e := range m {
+		if len(e.Key) > 0 && e.Key[0] == keyType {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Set replaces the entry of m with the key of e, or appends e
+// if there is none.
+func (m *Map) Set(e Entry) {
+	for i, d := range *m {
+		if bytes.Equal(d.Key, e.Key) {
+			(*m)[i] = e
+			return
+		}
+	}
+	*m = append(*m, e)
+}
+
+// lookup returns the entry of m with the given key.
+func (m Map) lookup(key []byte) (Entry, bool) {
+	for _, e := range m {
+		if bytes.Equal(e.Key, key) {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+const psbtMagic = "psbt\xff"
+
+// DefaultMaxSize is the default limit on decoded sizes.
+const DefaultMaxSize = 100 << 20
+
+// DecodeOptions bounds the resources spent decoding
+// untrusted input.
+type DecodeOptions struct {
+	// MaxSize is the maximum size of a PSBT or, for DecodeMap,
+	// a single map. Zero or negative means DefaultMaxSize.
+	MaxSize int
+	// MaxValueSize is the maximum size of a single key or value.
+	// Zero or negative means DefaultMaxSize.
+	MaxValueSize int
+	// StrictSighash rejects inputs with a sighash type that isn't
+	// Known with ErrUnknownSighash.
+	StrictSighash bool
+}
+
+func (o DecodeOptions) maxSize() int {
+	if o.MaxSize <= 0 {
+		return DefaultMaxSize
+	}
+	return o.MaxSize
+}
+
+func (o DecodeOptions) maxValueSize() int {
+	if o.MaxValueSize <= 0 {
+		return DefaultMaxSize
+	}
+	return o.MaxValueSize
+}
+
+// Decode decodes a PSBT with the default options.
+func Decode(data []byte) (PSBT, error) {
+	return DecodeOptions{}.Decode(data)
+}
+
+// Decode decodes a PSBT.
+func (o DecodeOptions) Decode(data []byte) (PSBT, error) {
+	if len(data) > o.maxSize() {
+		return PSBT{}, fmt.Errorf("psbt: %w: %d bytes", ErrTooLarge, len(data))
 	}
-	return k, nil
+	p, n, err := o.DecodePrefix(data)
+	if err != nil {
+		return PSBT{}, err
+	}
+	if n < len(data) {
+		return PSBT{}, fmt.Errorf("psbt: %w: %d bytes at offset %d", ErrTrailingData, len(data)-n, n)
+	}
+	return p, nil
 }
 
-func Decode(data []byte) error {
-	const PSBT_GLOBAL_UNSIGNED_TX = 0x00
+// DecodePrefix decodes a PSBT with the default options from the
+// start of data.
+func DecodePrefix(data []byte) (PSBT, int, error) {
+	return DecodeOptions{}.DecodePrefix(data)
+}
 
+// DecodePrefix decodes a PSBT from the start of data and returns it
+// along with the number of bytes consumed, including the magic and
+// the terminator of the last map. Any data following the PSBT is
+// ignored.
+func (o DecodeOptions) DecodePrefix(data []byte) (PSBT, int, error) {
 	// Verify magic.
-	const psbtMagic = "psbt\xff"
+	if len(data) < len(psbtMagic) {
+		return PSBT{}, 0, fmt.Errorf("psbt: %w: input too short (%d bytes)", ErrInvalidMagic, len(data))
+	}
 	if !bytes.HasPrefix(data, []byte(psbtMagic)) {
-		return errors.New("psbt: invalid magic")
+		return PSBT{}, 0, fmt.Errorf("psbt: %w", ErrInvalidMagic)
+	}
+	rest := data[len(psbtMagic):]
+
+	p, err := o.decode(func(off int) (Map, int, error) {
+		m, n, err := o.decodeMap(rest, off, keepAll)
+		rest = rest[n:]
+		if err == nil && off+n > o.maxSize() {
+			err = fmt.Errorf("%w: %d bytes", ErrTooLarge, off+n)
+		}
+		return m, n, err
+	})
+	if err != nil {
+		return PSBT{}, 0, err
 	}
-	data = data[len(psbtMagic):]
+	return p, len(data) - len(rest), nil
+}
 
+// decode decodes the maps of a PSBT as returned by next, which is
+// passed the offset of the map from the start of the PSBT. The
+// number of input and output maps is determined by the global map.
+// A zero byte count from next means there are no more maps.
+func (o DecodeOptions) decode(next func(off int) (Map, int, error)) (PSBT, error) {
 	// Read global map.
-	m, n, err := DecodeMap(data)
-	data = data[n:]
+	off := len(psbtMagic)
+	m, n, err := next(off)
+	off += n
 	if err != nil {
-		return fmt.Errorf("psbt: %w", err)
+		return PSBT{}, fmt.Errorf("psbt: %w", err)
+	}
+	if n == 0 {
+		// The global map must end with a separator, even if empty.
+		return PSBT{}, fmt.Errorf("psbt: %w: missing global map", ErrTruncated)
+	}
+	g, err := decodeGlobal(m)
+	if err != nil {
+		return PSBT{}, fmt.Errorf("psbt: %w", err)
+	}
+	p := PSBT{Global: g}
+
+	// Read input and output maps. The two counts are bounded
+	// by math.MaxInt32 each, so they are not added together.
+	nin, nout := p.Global.counts()
+	for i := 0; i < nin; i++ {
+		start := off
+		m, n, err := next(off)
+		off += n
+		if err != nil {
+			return PSBT{}, fmt.Errorf("psbt: %w", err)
+		}
+		if n == 0 {
+			return PSBT{}, fmt.Errorf("psbt: %w: expected %d inputs, but %d input maps follow", ErrTruncated, nin, i)
+		}
+		in, err := decodeInput(m)
+		if err == nil && o.StrictSighash && !in.knownSighash() {
+			err = fmt.Errorf("%w %#x", ErrUnknownSighash, uint32(in.SighashType))
+		}
+		if err != nil {
+			return PSBT{}, fmt.Errorf("psbt: input %d at offset %d: %w", i, start, err)
+		}
+		p.Inputs = append(p.Inputs, in)
+	}
+	for i := 0; i < nout; i++ {
+		start := off
+		m, n, err := next(off)
+		off += n
+		if err != nil {
+			return PSBT{}, fmt.Errorf("psbt: %w", err)
+		}
+		if n == 0 {
+			return PSBT{}, fmt.Errorf("psbt: %w: expected %d outputs, but %d output maps follow", ErrTruncated, nout, i)
+		}
+		out, err := decodeOutput(m)
+		if err != nil {
+			return PSBT{}, fmt.Errorf("psbt: output %d at offset %d: %w", i, start, err)
+		}
+		p.Outputs = append(p.Outputs, out)
+	}
+	return p, nil
+}
+
+// keyOnlyTypes are the known field types of each scope whose keys
+// hold no key data after the key type.
+var keyOnlyTypes = map[Scope][]byte{
+	ScopeGlobal: {
+		PSBT_GLOBAL_UNSIGNED_TX,
+		PSBT_GLOBAL_TX_VERSION,
+		PSBT_GLOBAL_FALLBACK_LOCKTIME,
+		PSBT_GLOBAL_INPUT_COUNT,
+		PSBT_GLOBAL_OUTPUT_COUNT,
+		PSBT_GLOBAL_VERSION,
+	},
+	ScopeInput: {
+		PSBT_IN_NON_WITNESS_UTXO,
+		PSBT_IN_WITNESS_UTXO,
+		PSBT_IN_SIGHASH_TYPE,
+		PSBT_IN_REDEEM_SCRIPT,
+		PSBT_IN_WITNESS_SCRIPT,
+		PSBT_IN_FINAL_SCRIPTSIG,
+		PSBT_IN_FINAL_SCRIPTWITNESS,
+		PSBT_IN_TAP_KEY_SIG,
+		PSBT_IN_TAP_INTERNAL_KEY,
+	},
+	ScopeOutput: {
+		PSBT_OUT_REDEEM_SCRIPT,
+		PSBT_OUT_WITNESS_SCRIPT,
+	},
+}
+
+// checkKey checks that the key of e is not empty, and that it holds
+// no key data if its type in scope doesn't allow any.
+func checkKey(scope Scope, e Entry) error {
+	if len(e.Key) == 0 {
+		return errors.New("empty key")
+	}
+	if len(e.Key) != 1 && slices.Contains(keyOnlyTypes[scope], e.Key[0]) {
+		return fmt.Errorf("invalid %s key %#x", KeyTypeName(scope, e.Key[0]), e.Key)
 	}
+	return nil
+}
+
+// keyData returns the key of e after the key type, or nil
+// if the key is empty.
+func keyData(e Entry) []byte {
+	if len(e.Key) == 0 {
+		return nil
+	}
+	return e.Key[1:]
+}
+
+func decodeGlobal(m Map) (Global, error) {
+	g := Global{Entries: m}
+	var hasTx, hasTxVersion, hasInputCount, hasOutputCount, hasV2Fields bool
 	for _, e := range m {
+		if err := checkKey(ScopeGlobal, e); err != nil {
+			return Global{}, err
+		}
 		switch k := e.Key[0]; k {
 		case PSBT_GLOBAL_UNSIGNED_TX:
-			fmt.Printf("PSBT_GLOBAL_UNSIGNED_TX: %#x\n", e.Val)
-		default:
-			fmt.Printf("Unknown global entry: key %#x, value %#x\n", k, e.Val)
+			if hasWitnessMarker(e.Val) {
+				return Global{}, fmt.Errorf("invalid unsigned transaction: %w: segwit marker", ErrSignedTx)
+			}
+			tx, err := DecodeTransaction(e.Val)
+			if err != nil {
+				return Global{}, fmt.Errorf("invalid unsigned transaction: %w", err)
+			}
+			for i, in := range tx.Inputs {
+				if len(in.ScriptSig) > 0 {
+					return Global{}, fmt.Errorf("invalid unsigned transaction: %w: input %d scriptSig", ErrSignedTx, i)
+				}
+			}
+			g.UnsignedTx = tx
+			hasTx = true
+		case PSBT_GLOBAL_XPUB:
+			k, err := DecodePSBTXpub(e)
+			if err != nil {
+				return Global{}, fmt.Errorf("invalid global xpub: %w", err)
+			}
+			g.Xpubs = append(g.Xpubs, k)
+		case PSBT_GLOBAL_TX_VERSION:
+			v, err := decodeUint32(e)
+			if err != nil {
+				return Global{}, fmt.Errorf("invalid transaction version: %w", err)
+			}
+			g.TxVersion = v
+			hasTxVersion, hasV2Fields = true, true
+		case PSBT_GLOBAL_FALLBACK_LOCKTIME:
+			v, err := decodeUint32(e)
+			if err != nil {
+				return Global{}, fmt.Errorf("invalid fallback locktime: %w", err)
+			}
+			g.FallbackLocktime = v
+			hasV2Fields = true
+		case PSBT_GLOBAL_INPUT_COUNT:
+			c, err := decodeCount(e)
+			if err != nil {
+				return Global{}, fmt.Errorf("invalid input count: %w", err)
+			}
+			g.InputCount = c
+			hasInputCount, hasV2Fields = true, true
+		case PSBT_GLOBAL_OUTPUT_COUNT:
+			c, err := decodeCount(e)
+			if err != nil {
+				return Global{}, fmt.Errorf("invalid output count: %w", err)
+			}
+			g.OutputCount = c
+			hasOutputCount, hasV2Fields = true, true
+		case PSBT_GLOBAL_VERSION:
+			v, err := decodeUint32(e)
+			if err != nil {
+				return Global{}, fmt.Errorf("invalid version: %w", err)
+			}
+			if v > MaxVersion {
+				return Global{}, fmt.Errorf("%w %d", ErrUnsupportedVersion, v)
+			}
+			g.Version = v
+		}
+	}
+	switch g.Version {
+	case 2:
+		switch {
+		case hasTx:
+			return Global{}, errors.New("unsigned transaction not allowed in version 2")
+		case !hasTxVersion:
+			return Global{}, errors.New("missing transaction version")
+		case !hasInputCount:
+			return Global{}, errors.New("missing input count")
+		case !hasOutputCount:
+			return Global{}, errors.New("missing output count")
+		}
+	default:
+		switch {
+		case !hasTx:
+			return Global{}, errors.New("missing unsigned transaction")
+		case hasV2Fields:
+			return Global{}, fmt.Errorf("version 2 fields not allowed in version %d", g.Version)
 		}
 	}
+	return g, nil
+}
 
-	// Read input and output maps.
-	for {
-		m, n, err := DecodeMap(data)
-		data = data[n:]
-		if err != nil {
-			return fmt.Errorf("psbt: %w", err)
+func decodeInput(m Map) (Input, error) {
+	in := Input{Entries: m}
+	for _, e := range m {
+		if err := checkKey(ScopeInput, e); err != nil {
+			return Input{}, err
 		}
-		if n == 0 {
-			// No more maps.
-			break
+		switch k := e.Key[0]; k {
+		case PSBT_IN_NON_WITNESS_UTXO:
+			in.NonWitnessUTXO = e.Val
+		case PSBT_IN_WITNESS_UTXO:
+			out, err := DecodeTxOut(e.Val)
+			if err != nil {
+				return Input{}, fmt.Errorf("invalid witness utxo: %w", err)
+			}
+			in.WitnessUTXO = &out
+		case PSBT_IN_PARTIAL_SIG:
+			pub := keyData(e)
+			if len(pub) != compressedPubKeyLen {
+				return Input{}, fmt.Errorf("invalid partial signature public key length %d", len(pub))
+			}
+			if len(e.Val) == 0 {
+				return Input{}, errors.New("empty partial signature")
+			}
+			in.PartialSigs = append(in.PartialSigs, PartialSig{
+				PubKey:    pub,
+				Signature: e.Val,
+			})
+		case PSBT_IN_SIGHASH_TYPE:
+			v, err := decodeUint32(e)
+			if err != nil {
+				return Input{}, fmt.Errorf("invalid sighash type: %w", err)
+			}
+			in.SighashType = SighashType(v)
+			in.HasSighashType = true
+		case PSBT_IN_REDEEM_SCRIPT:
+			in.RedeemScript = e.Val
+		case PSBT_IN_WITNESS_SCRIPT:
+			in.WitnessScript = e.Val
+		case PSBT_IN_FINAL_SCRIPTSIG:
+			in.FinalScriptSig = e.Val
+		case PSBT_IN_FINAL_SCRIPTWITNESS:
+			in.FinalScriptWitness = e.Val
+		case PSBT_IN_BIP32_DERIVATION:
+			d, err := DecodeDerivation(e)
+			if err != nil {
+				return Input{}, fmt.Errorf("invalid derivation: %w", err)
+			}
+			in.Derivations = append(in.Derivations, d)
+		case PSBT_IN_TAP_KEY_SIG:
+			if !validSchnorrSigLen(len(e.Val)) {
+				return Input{}, fmt.Errorf("invalid taproot key signature length %d", len(e.Val))
+			}
+			in.TapKeySig = e.Val
+		case PSBT_IN_TAP_SCRIPT_SIG:
+			sig, err := DecodeTapScriptSig(e)
+			if err != nil {
+				return Input{}, fmt.Errorf("invalid taproot script signature: %w", err)
+			}
+			in.TapScriptSigs = append(in.TapScriptSigs, sig)
+		case PSBT_IN_TAP_LEAF_SCRIPT:
+			l, err := DecodeTapLeafScript(e)
+			if err != nil {
+				return Input{}, fmt.Errorf("invalid taproot leaf script: %w", err)
+			}
+			in.TapLeafScripts = append(in.TapLeafScripts, l)
+		case PSBT_IN_TAP_BIP32_DERIVATION:
+			d, err := DecodeTapDerivation(e)
+			if err != nil {
+				return Input{}, fmt.Errorf("invalid taproot derivation: %w", err)
+			}
+			in.TapDerivations = append(in.TapDerivations, d)
+		case PSBT_IN_TAP_INTERNAL_KEY:
+			if len(e.Val) != xOnlyPubKeyLen {
+				return Input{}, errors.New("invalid taproot internal key")
+			}
+			in.TapInternalKey = e.Val
 		}
-		fmt.Println("\nInput/output map:")
-		for _, e := range m {
-			switch k := e.Key[0]; k {
-			default:
-				fmt.Printf("Unknown input/output entry: key %#x, value %#x\n", k, e.Val)
+	}
+	if in.NonWitnessUTXO != nil && in.WitnessUTXO != nil {
+		return Input{}, errors.New("both witness and non-witness utxo present")
+	}
+	if !in.HasSighashType && !in.Taproot() {
+		in.SighashType = SighashAll
+	}
+	return in, nil
+}
+
+func decodeOutput(m Map) (Output, error) {
+	out := Output{Entries: m}
+	for _, e := range m {
+		if err := checkKey(ScopeOutput, e); err != nil {
+			return Output{}, err
+		}
+		switch k := e.Key[0]; k {
+		case PSBT_OUT_REDEEM_SCRIPT:
+			out.RedeemScript = e.Val
+		case PSBT_OUT_WITNESS_SCRIPT:
+			out.WitnessScript = e.Val
+		case PSBT_OUT_BIP32_DERIVATION:
+			d, err := DecodeDerivation(e)
+			if err != nil {
+				return Output{}, fmt.Errorf("invalid derivation: %w", err)
 			}
+			out.Derivations = append(out.Derivations, d)
+		}
+	}
+	return out, nil
+}
+
+// decodeUint32 decodes a 4-byte little-endian value.
+func decodeUint32(e Entry) (uint32, error) {
+	if len(e.Val) != 4 {
+		return 0, fmt.Errorf("invalid length %d", len(e.Val))
+	}
+	return binary.LittleEndian.Uint32(e.Val), nil
+}
+
+// decodeCount decodes a compact size count.
+func decodeCount(e Entry) (int, error) {
+	c, n, err := decodeVarInt(e.Val)
+	if err != nil {
+		return 0, err
+	}
+	if n != len(e.Val) {
+		return 0, errors.New("trailing data after compact size")
+	}
+	if c > math.MaxInt32 {
+		return 0, fmt.Errorf("count %d out of range", c)
+	}
+	return int(c), nil
+}
+
+// Encode serializes a PSBT from the entries of its maps, sorted
+// by key. The typed fields are derived from the entries and are
+// not consulted.
+func Encode(p PSBT) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteString(psbtMagic)
+	if err := validateMap(p.Global.Entries); err != nil {
+		return nil, fmt.Errorf("psbt: global: %w", err)
+	}
+	EncodeMap(buf, p.Global.Entries)
+	for i, in := range p.Inputs {
+		if err := validateMap(in.Entries); err != nil {
+			return nil, fmt.Errorf("psbt: input %d: %w", i, err)
+		}
+		EncodeMap(buf, in.Entries)
+	}
+	for i, out := range p.Outputs {
+		if err := validateMap(out.Entries); err != nil {
+			return nil, fmt.Errorf("psbt: output %d: %w", i, err)
+		}
+		EncodeMap(buf, out.Entries)
+	}
+	return buf.Bytes(), nil
+}
+
+// validateMap checks that m can be encoded unambiguously.
+func validateMap(m Map) error {
+	for _, e := range m {
+		if len(e.Key) == 0 {
+			return errors.New("empty key")
 		}
 	}
 	return nil
 }
 
+// EncodeMap writes the entries of a map followed by the
+// map separator. Entries are written in lexicographic order
+// of their keys for a deterministic encoding.
+func EncodeMap(w *bytes.Buffer, m Map) {
+	sorted := slices.Clone(m)
+	slices.SortStableFunc(sorted, func(a, b Entry) int {
+		return bytes.Compare(a.Key, b.Key)
+	})
+	for _, e := range sorted {
+		e.Write(w)
+	}
+	w.WriteByte(0x00)
+}
+
 type Entry struct {
 	Key, Val []byte
 }
 
+// Write appends the serialization of e to w.
 func (e Entry) Write(w *bytes.Buffer) {
-	writeVarInt(w, uint64(len(e.Key)))
+	WriteVarInt(w, uint64(len(e.Key)))
 	w.Write(e.Key)
-	writeVarInt(w, uint64(len(e.Val)))
+	WriteVarInt(w, uint64(len(e.Val)))
 	w.Write(e.Val)
 }
 
-func writeVarInt(w *bytes.Buffer, v uint64) {
+// WriteTo writes the serialization of e to w. It implements
+// io.WriterTo.
+func (e Entry) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, b := range [][]byte{e.Key, e.Val} {
+		lenBuf := new(bytes.Buffer)
+		WriteVarInt(lenBuf, uint64(len(b)))
+		n, err := w.Write(lenBuf.Bytes())
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+		n, err = w.Write(b)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Len returns the length of the serialization of e.
+func (e Entry) Len() int {
+	return VarIntSize(uint64(len(e.Key))) + len(e.Key) + VarIntSize(uint64(len(e.Val))) + len(e.Val)
+}
+
+// WriteVarInt writes v in the shortest variable length
+// integer encoding.
+func WriteVarInt(w *bytes.Buffer, v uint64) {
 	bo := binary.LittleEndian
 	switch {
 	case v < 0xfd:
@@ -101,87 +833,273 @@ func writeVarInt(w *bytes.Buffer, v uint64) {
 	case v <= 0xffff:
 		var buf [2]uint8
 		bo.PutUint16(buf[:], uint16(v))
+		w.WriteByte(0xfd)
 		w.Write(buf[:])
 	case v <= 0xffff_ffff:
 		var buf [4]uint8
 		bo.PutUint32(buf[:], uint32(v))
+		w.WriteByte(0xfe)
 		w.Write(buf[:])
 	default:
 		var buf [8]uint8
 		bo.PutUint64(buf[:], uint64(v))
+		w.WriteByte(0xff)
 		w.Write(buf[:])
 	}
 }
 
-func DecodeMap(data []byte) ([]Entry, int, error) {
-	var m []Entry
+// DecodeMap decodes a map with the default options.
+func DecodeMap(data []byte) (Map, int, error) {
+	return DecodeOptions{}.DecodeMap(data)
+}
+
+// DecodeMap decodes a map from the beginning of data and returns
+// its entries and the number of bytes consumed.
+//
+// A map ends with the separator, a single 0x00 byte in the place of
+// a key length. Because key and value lengths are length-prefixed and
+// must be minimally encoded, a 0x00 byte inside a key or value is never
+// mistaken for the separator, and no other encoding of a zero key
+// length is accepted. The byte count includes the separator, so any
+// following bytes, such as the next map, are at data[n:].
+func (o DecodeOptions) DecodeMap(data []byte) (Map, int, error) {
+	return o.DecodeMapFiltered(data, keepAll)
+}
+
+// DecodeMapFiltered decodes a map with the default options,
+// retaining only the entries accepted by keep.
+func DecodeMapFiltered(data []byte, keep func(keyType byte) bool) (Map, int, error) {
+	return DecodeOptions{}.DecodeMapFiltered(data, keep)
+}
+
+// DecodeMapFiltered is like DecodeMap, but retains only the entries
+// whose key type is accepted by keep. Every entry is still parsed and
+// the number of bytes consumed covers the whole map. Duplicate keys
+// are only detected among the retained entries.
+func (o DecodeOptions) DecodeMapFiltered(data []byte, keep func(keyType byte) bool) (Map, int, error) {
+	return o.decodeMap(data, 0, keep)
+}
+
+// keepAll is the DecodeMapFiltered predicate that retains
+// every entry.
+func keepAll(byte) bool {
+	return true
+}
+
+// decodeMap is like DecodeMapFiltered, but reports errors relative
+// to the offset of data in a larger input.
+func (o DecodeOptions) decodeMap(data []byte, off int, keep func(keyType byte) bool) (Map, int, error) {
+	count := countEntries(data, keep)
+	m := make(Map, 0, count)
+	seen := make(map[string]bool, count)
+	n, err := o.rangeMap(data, off, func(key, val []byte, entryOff int) error {
+		if !keep(key[0]) {
+			return nil
+		}
+		var err error
+		m, err = addEntry(m, seen, key, val)
+		if err != nil {
+			return fmt.Errorf("%w at offset %d", err, entryOff)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, n, err
+	}
+	return m, n, nil
+}
+
+// RangeMap calls fn for each entry of a map with the default options.
+func RangeMap(data []byte, fn func(key, val []byte) error) (int, error) {
+	return DecodeOptions{}.RangeMap(data, fn)
+}
+
+// RangeMap calls fn for each entry of the map at the beginning of data,
+// in order, and returns the number of bytes consumed. The key and value
+// passed to fn are sub-slices of data. Unlike DecodeMap, RangeMap doesn't
+// detect duplicate keys. An error from fn stops the iteration and is
+// returned as is.
+func (o DecodeOptions) RangeMap(data []byte, fn func(key, val []byte) error) (int, error) {
+	return o.rangeMap(data, 0, func(key, val []byte, _ int) error {
+		return fn(key, val)
+	})
+}
+
+// rangeMap is like RangeMap, but reports errors relative to the offset
+// of data in a larger input, and passes the entry offset to fn.
+func (o DecodeOptions) rangeMap(data []byte, off int, fn func(key, val []byte, off int) error) (int, error) {
 	n := 0
 	for {
-		key, val, n1, err := decodeKeyVal(data)
+		if n > o.maxSize() {
+			return n, fmt.Errorf("%w: map at offset %d exceeds %d bytes", ErrTooLarge, off, o.maxSize())
+		}
+		key, val, n1, err := o.decodeKeyVal(data, off+n)
 		data = data[n1:]
 		n += n1
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return m, n, nil
+		switch {
+		case errors.Is(err, errEndOfMap):
+			return n, nil
+		case errors.Is(err, io.EOF):
+			if n == 0 {
+				// No map.
+				return 0, nil
 			}
-			return nil, n, err
+			return n, fmt.Errorf("%w: missing separator of map at offset %d", ErrTruncated, off)
+		case err != nil:
+			return n, err
+		}
+		if err := fn(key, val, off+n-n1); err != nil {
+			return n, err
 		}
-		m = append(m, Entry{key, val})
 	}
 }
 
-func decodeKeyVal(data []byte) ([]byte, []byte, int, error) {
-	keyLen, n1 := decodeVarInt(data)
-	data = data[n1:]
-	if n1 == 0 || keyLen > uint64(len(data)) {
+// countEntries returns the number of entries of the map at the start
+// of data accepted by keep, for preallocation. The count stops at the
+// first malformed entry.
+func countEntries(data []byte, keep func(keyType byte) bool) int {
+	n := 0
+	for {
+		keyLen, n1, err := decodeVarInt(data)
+		if err != nil || keyLen == 0 || keyLen > uint64(len(data)-n1) {
+			return n
+		}
+		keyType := data[n1]
+		data = data[n1+int(keyLen):]
+		valLen, n2, err := decodeVarInt(data)
+		if err != nil || valLen > uint64(len(data)-n2) {
+			return n
+		}
+		data = data[n2+int(valLen):]
+		if keep(keyType) {
+			n++
+		}
+	}
+}
+
+// addEntry appends an entry to m, checking for duplicate keys.
+func addEntry(m Map, seen map[string]bool, key, val []byte) (Map, error) {
+	if seen[string(key)] {
+		return nil, fmt.Errorf("%w %#x", ErrDuplicateKey, key)
+	}
+	seen[string(key)] = true
+	return append(m, Entry{key, val}), nil
+}
+
+// errEndOfMap is returned by decodeKeyVal for the separator
+// that ends a map.
+var errEndOfMap = errors.New("end of map")
+
+// decodeKeyVal decodes an entry at offset off. It returns errEndOfMap
+// for the map separator and io.EOF if data is empty. The separator is
+// always the single byte 0x00, because decodeVarInt rejects longer
+// encodings of zero.
+//
+// Declared lengths are compared as uint64 against the remaining data
+// before any conversion to int, so lengths beyond the range of a 32-bit
+// int result in errors rather than truncated values or panics.
+func (o DecodeOptions) decodeKeyVal(data []byte, off int) ([]byte, []byte, int, error) {
+	if len(data) == 0 {
 		return nil, nil, 0, io.EOF
 	}
+	keyLen, n1, err := decodeVarInt(data)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("%w: key length at offset %d", err, off)
+	}
+	if keyLen > uint64(o.maxValueSize()) {
+		return nil, nil, 0, fmt.Errorf("%w: key length %d at offset %d", ErrTooLarge, keyLen, off)
+	}
+	data = data[n1:]
 	if keyLen == 0 {
-		// End of map.
-		return nil, nil, n1, io.EOF
+		return nil, nil, n1, errEndOfMap
+	}
+	if keyLen > uint64(len(data)) {
+		return nil, nil, 0, fmt.Errorf("%w: key at offset %d", ErrTruncated, off+n1)
 	}
 	key := data[:keyLen]
 	data = data[keyLen:]
-	valLen, n2 := decodeVarInt(data)
+	valOff := off + n1 + int(keyLen)
+	valLen, n2, err := decodeVarInt(data)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("%w: value length at offset %d", err, valOff)
+	}
+	if valLen > uint64(o.maxValueSize()) {
+		return nil, nil, 0, fmt.Errorf("%w: value length %d at offset %d", ErrTooLarge, valLen, valOff)
+	}
 	data = data[n2:]
-	if n2 == 0 || valLen > uint64(len(data)) {
-		return nil, nil, 0, io.ErrUnexpectedEOF
+	if valLen > uint64(len(data)) {
+		return nil, nil, 0, fmt.Errorf("%w: value at offset %d", ErrTruncated, valOff)
 	}
 	val := data[:valLen]
 	data = data[valLen:]
 	return key, val, n1 + n2 + int(keyLen+valLen), nil
 }
 
+// VarIntSize returns the length of the variable length
+// integer encoding of v.
+func VarIntSize(v uint64) int {
+	switch {
+	case v < 0xfd:
+		return 1
+	case v <= 0xffff:
+		return 3
+	case v <= 0xffff_ffff:
+		return 5
+	default:
+		return 9
+	}
+}
+
+// ReadVarInt decodes a variable length integer from the beginning
+// of data and returns its value and encoded length. The length is 0
+// if data is truncated or the value is not in its shortest encoding.
+//
 // https://en.bitcoin.it/wiki/Protocol_documentation#Variable_length_integer.
-func decodeVarInt(data []byte) (uint64, int) {
-	if len(data) == 0 {
+func ReadVarInt(data []byte) (uint64, int) {
+	v, n, err := decodeVarInt(data)
+	if err != nil {
 		return 0, 0
 	}
+	return v, n
+}
+
+// decodeVarInt is like ReadVarInt, but returns ErrTruncated if data
+// is too short, and ErrNonCanonicalVarInt if the value is not in its
+// shortest encoding.
+func decodeVarInt(data []byte) (uint64, int, error) {
+	if len(data) == 0 {
+		return 0, 0, ErrTruncated
+	}
 	bo := binary.LittleEndian
-	switch v := data[0]; v {
+	var v uint64
+	var n int
+	var min uint64
+	switch data[0] {
 	case 0xfd:
 		// 16 bit value.
 		if len(data) < 3 {
-			return 0, 0
+			return 0, 0, ErrTruncated
 		}
-		v := bo.Uint16(data[1:])
-		return uint64(v), 3
+		v, n, min = uint64(bo.Uint16(data[1:])), 3, 0xfd
 	case 0xfe:
 		// 32 bit value.
 		if len(data) < 5 {
-			return 0, 0
+			return 0, 0, ErrTruncated
 		}
-		v := bo.Uint32(data[1:])
-		return uint64(v), 5
+		v, n, min = uint64(bo.Uint32(data[1:])), 5, 0x1_0000
 	case 0xff:
 		// 64 bit value.
 		if len(data) < 9 {
-			return 0, 0
+			return 0, 0, ErrTruncated
 		}
-		v := bo.Uint64(data[1:])
-		return v, 9
+		v, n, min = bo.Uint64(data[1:]), 9, 0x1_0000_0000
 	default:
 		// 8 bit value.
-		return uint64(v), 1
+		return uint64(data[0]), 1, nil
+	}
+	// Reject values that fit a shorter encoding.
+	if v < min {
+		return 0, 0, fmt.Errorf("%w: %d encoded in %d bytes", ErrNonCanonicalVarInt, v, n)
 	}
+	return v, n, nil
 }
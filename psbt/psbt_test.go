@@ -0,0 +1,373 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// testPSBTs are valid PSBTs from the test vectors of BIP-174, which
+// spend testnet coins.
+var testPSBTs = []struct {
+	name   string
+	base64 string
+}{
+	{
+		"P2PKH input",
+		"cHNidP8BAHUCAAAAASaBcTce3/KF6Tet7qSze3gADAVmy7OtZGQXE8pCFxv2AAAAAAD+////AtPf9QUAAAAAGXapFNDFmQPFusKGh2DpD9UhpGZap2UgiKwA4fUFAAAAABepFDVF5uM7gyxHBQ8k0+65PJwDlIvHh7MuEwAAAQD9pQEBAAAAAAECiaPHHqtNIOA3G7ukzGmPopXJRjr6Ljl/hTPMti+VZ+UBAAAAFxYAFL4Y0VKpsBIDna89p95PUzSe7LmF/////4b4qkOnHf8USIk6UwpyN+9rRgi7st0tAXHmOuxqSJC0AQAAABcWABT+Pp7xp0XpdNkCxDVZQ6vLNL1TU/////8CAMLrCwAAAAAZdqkUhc/xCX/Z4Ai7NK9wnGIZeziXikiIrHL++E4sAAAAF6kUM5cluiHv1irHU6m80GfWx6ajnQWHAkcwRAIgJxK+IuAnDzlPVoMR3HyppolwuAJf3TskAinwf4pfOiQCIAGLONfc0xTnNMkna9b7QPZzMlvEuqFEyADS8vAtsnZcASED0uFWdJQbrUqZY3LLh+GFbTZSYG2YVi/jnF6efkE/IQUCSDBFAiEA0SuFLYXc2WHS9fSrZgZU327tzHlMDDPOXMMJ/7X85Y0CIGczio4OFyXBl/saiK9Z9R5E5CVbIBZ8hoQDHAXR8lkqASECI7cr7vCWXRC+B3jv7NYfysb3mk6haTkzgHNEZPhPKrMAAAAAAAAA",
+	},
+	{
+		"P2SH-P2WSH multisig input with one signature",
+		"cHNidP8BAFUCAAAAASeaIyOl37UfxF8iD6WLD8E+HjNCeSqF1+Ns1jM7XLw5AAAAAAD/////AaBa6gsAAAAAGXapFP/pwAYQl8w7Y28ssEYPpPxCfStFiKwAAAAAAAEBIJVe6gsAAAAAF6kUY0UgD2jRieGtwN8cTRbqjxTA2+uHIgIDsTQcy6doO2r08SOM1ul+cWfVafrEfx5I1HVBhENVvUZGMEMCIAQktY7/qqaU4VWepck7v9SokGQiQFXN8HC2dxRpRC0HAh9cjrD+plFtYLisszrWTt5g6Hhb+zqpS5m9+GFR25qaAQEEIgAgdx/RitRZZm3Unz1WTj28QvTIR3TjYK2haBao7UiNVoEBBUdSIQOxNBzLp2g7avTxI4zW6X5xZ9Vp+sR/HkjUdUGEQ1W9RiED3lXR4drIBeP4pYwfv5uUwC89uq/hJ/78pJlfJvggg71SriIGA7E0HMunaDtq9PEjjNbpfnFn1Wn6xH8eSNR1QYRDVb1GELSmumcAAACAAAAAgAQAAIAiBgPeVdHh2sgF4/iljB+/m5TALz26r+En/vykmV8m+CCDvRC0prpnAAAAgAAAAIAFAACAAAA=",
+	},
+	{
+		"finalized P2PKH input and P2SH-P2WPKH input",
+		"cHNidP8BAKACAAAAAqsJSaCMWvfEm4IS9Bfi8Vqz9cM9zxU4IagTn4d6W3vkAAAAAAD+////qwlJoIxa98SbghL0F+LxWrP1wz3PFTghqBOfh3pbe+QBAAAAAP7///8CYDvqCwAAAAAZdqkUdopAu9dAy+gdmI5x3ipNXHE5ax2IrI4kAAAAAAAAGXapFG9GILVT+glechue4O/p+gOcykWXiKwAAAAAAAEHakcwRAIgR1lmF5fAGwNrJZKJSGhiGDR9iYZLcZ4ff89X0eURZYcCIFMJ6r9Wqk2Ikf/REf3xM286KdqGbX+EhtdVRs7tr5MZASEDXNxh/HupccC1AaZGoqg7ECy0OIEhfKaC3Ibi1z+ogpIAAQEgAOH1BQAAAAAXqRQ1RebjO4MsRwUPJNPuuTycA5SLx4cBBBYAFIXRNTfy4mVAWjTbr6nj3aAfuCMIAAAA",
+	},
+	{
+		"creator output with two inputs and outputs",
+		"cHNidP8BAJoCAAAAAljoeiG1ba8MI76OcHBFbDNvfLqlyHV5JPVFiHuyq911AAAAAAD/////g40EJ9DsZQpoqka7CwmK6kQiwHGyyng1Kgd5WdB86h0BAAAAAP////8CcKrwCAAAAAAWABTYXCtx0AYLCcmIauuBXlCZHdoSTQDh9QUAAAAAFgAUAK6pouXw+HaliN9VRuh0LR2HAI8AAAAAAAAAAAA=",
+	},
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, test := range testPSBTs {
+		data, err := base64.StdEncoding.DecodeString(test.base64)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		p, err := Decode(data)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		enc, err := Encode(p)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(enc, data) {
+			t.Errorf("%s: round trip mismatch:\n%x\n%x", test.name, enc, data)
+		}
+		s, err := EncodeBase64(p)
+		if err != nil || s != test.base64 {
+			t.Errorf("%s: EncodeBase64 = %s, %v, want %s", test.name, s, err, test.base64)
+		}
+	}
+}
+
+func TestEncodeMapRoundTrip(t *testing.T) {
+	m := Map{
+		{Key: []byte{PSBT_GLOBAL_UNSIGNED_TX}, Val: []byte{0x02, 0x00, 0x00, 0x00}},
+		{Key: []byte{PSBT_GLOBAL_XPUB, 0x00}, Val: nil},
+		{Key: []byte{PSBT_GLOBAL_VERSION}, Val: bytes.Repeat([]byte{0xab}, 0xfd)},
+		{Key: []byte{0xfc, 0x00}, Val: []byte{0x00}},
+	}
+	buf := new(bytes.Buffer)
+	EncodeMap(buf, m)
+	// Append the start of a following map.
+	buf.WriteByte(0x01)
+	got, n, err := DecodeMap(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != buf.Len()-1 {
+		t.Errorf("DecodeMap consumed %d bytes, want %d", n, buf.Len()-1)
+	}
+	if len(got) != len(m) {
+		t.Fatalf("DecodeMap(EncodeMap(m)) has %d entries, want %d", len(got), len(m))
+	}
+	for i, e := range got {
+		if !bytes.Equal(e.Key, m[i].Key) || !bytes.Equal(e.Val, m[i].Val) {
+			t.Errorf("entry %d: %x=%x, want %x=%x", i, e.Key, e.Val, m[i].Key, m[i].Val)
+		}
+	}
+}
+
+func TestVarInt(t *testing.T) {
+	tests := []struct {
+		v   uint64
+		enc string
+	}{
+		{0, "00"},
+		{0xfc, "fc"},
+		{0xfd, "fdfd00"},
+		{0xffff, "fdffff"},
+		{0x10000, "fe00000100"},
+		{0xffffffff, "feffffffff"},
+		{0x100000000, "ff0000000001000000"},
+		{0xffffffffffffffff, "ffffffffffffffffff"},
+	}
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		WriteVarInt(buf, test.v)
+		if got := hex.EncodeToString(buf.Bytes()); got != test.enc {
+			t.Errorf("WriteVarInt(%#x) = %s, want %s", test.v, got, test.enc)
+		}
+		if n := VarIntSize(test.v); n != buf.Len() {
+			t.Errorf("VarIntSize(%#x) = %d, want %d", test.v, n, buf.Len())
+		}
+		v, n, err := decodeVarInt(buf.Bytes())
+		if err != nil || v != test.v || n != buf.Len() {
+			t.Errorf("decodeVarInt(%s) = %#x, %d, %v, want %#x, %d", test.enc, v, n, err, test.v, buf.Len())
+		}
+	}
+}
+
+func TestVarIntNonCanonical(t *testing.T) {
+	for _, enc := range []string{
+		"fd0000",
+		"fdfc00",
+		"fe00000000",
+		"feffff0000",
+		"ff0000000000000000",
+		"ffffffffff00000000",
+	} {
+		if _, _, err := decodeVarInt(mustHex(t, enc)); !errors.Is(err, ErrNonCanonicalVarInt) {
+			t.Errorf("decodeVarInt(%s) returned %v, want %v", enc, err, ErrNonCanonicalVarInt)
+		}
+	}
+	for _, enc := range []string{"", "fd00", "fe000001", "ff00000000010000"} {
+		if _, _, err := decodeVarInt(mustHex(t, enc)); !errors.Is(err, ErrTruncated) {
+			t.Errorf("decodeVarInt(%q) returned %v, want %v", enc, err, ErrTruncated)
+		}
+	}
+}
+
+func TestDecodeShort(t *testing.T) {
+	for n := 0; n < len(psbtMagic); n++ {
+		if _, err := Decode([]byte(psbtMagic[:n])); !errors.Is(err, ErrInvalidMagic) {
+			t.Errorf("Decode(%x) returned %v, want %v", psbtMagic[:n], err, ErrInvalidMagic)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte(psbtMagic[:3]))
+	f.Add([]byte(psbtMagic))
+	f.Add([]byte(psbtMagic + "\x00"))
+	f.Add([]byte(psbtMagic + "\x01\x00\x00\x00"))
+	for _, test := range testPSBTs {
+		data, err := base64.StdEncoding.DecodeString(test.base64)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := Decode(data)
+		if err != nil {
+			return
+		}
+		if _, err := Encode(p); err != nil {
+			t.Errorf("Encode of decoded %x: %v", data, err)
+		}
+	})
+}
+
+func TestDecodeVectors(t *testing.T) {
+	tests := []struct {
+		inputs  int
+		outputs int
+		// fingerprints are the master fingerprints of the
+		// derivations of each input.
+		fingerprints [][]uint32
+	}{
+		{1, 2, [][]uint32{nil}},
+		{1, 1, [][]uint32{{0xb4a6ba67, 0xb4a6ba67}}},
+		{2, 2, [][]uint32{nil, nil}},
+		{2, 2, [][]uint32{nil, nil}},
+	}
+	for i, test := range tests {
+		name := testPSBTs[i].name
+		p, err := DecodeBase64(testPSBTs[i].base64)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(p.Inputs) != test.inputs || len(p.Outputs) != test.outputs {
+			t.Errorf("%s: %d inputs and %d outputs, want %d and %d", name, len(p.Inputs), len(p.Outputs), test.inputs, test.outputs)
+			continue
+		}
+		for i, in := range p.Inputs {
+			var fps []uint32
+			for _, d := range in.Derivations {
+				fps = append(fps, d.MasterFingerprint)
+			}
+			if !slices.Equal(fps, test.fingerprints[i]) {
+				t.Errorf("%s: input %d fingerprints %08x, want %08x", name, i, fps, test.fingerprints[i])
+			}
+		}
+	}
+}
+
+func TestDecodeMapAdjacent(t *testing.T) {
+	// Values of zero bytes and an empty map must not be mistaken
+	// for separators.
+	maps := []Map{
+		{{Key: []byte{0x00}, Val: []byte{0x00, 0x00}}, {Key: []byte{0x01, 0x00}, Val: []byte{}}},
+		{},
+		{{Key: []byte{0x02}, Val: []byte{0x00}}},
+	}
+	buf := new(bytes.Buffer)
+	var ends []int
+	for _, m := range maps {
+		EncodeMap(buf, m)
+		ends = append(ends, buf.Len())
+	}
+	data := buf.Bytes()
+	off := 0
+	for i, want := range maps {
+		m, n, err := DecodeMap(data[off:])
+		if err != nil {
+			t.Fatalf("map %d: %v", i, err)
+		}
+		off += n
+		if off != ends[i] {
+			t.Fatalf("map %d ends at offset %d, want %d", i, off, ends[i])
+		}
+		if len(m) != len(want) {
+			t.Fatalf("map %d has %d entries, want %d", i, len(m), len(want))
+		}
+	}
+	// The separator is a single 0x00 byte; a longer encoding of
+	// a zero key length is rejected.
+	if _, _, err := DecodeMap(mustHex(t, "fd0000")); !errors.Is(err, ErrNonCanonicalVarInt) {
+		t.Errorf("DecodeMap accepted a non-canonical separator: %v", err)
+	}
+}
+
+func TestDecodeMapHugeLength(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		// Key length of 2^64-1.
+		{"key", "ffffffffffffffffff00"},
+		// Key length of 2^32, beyond the range of a 32-bit int.
+		{"key", "ff000000000100000000"},
+		// Value length of 2^63 after a one byte key.
+		{"value", "0100ff0000000000000080"},
+		// Value length of 2^32 after a one byte key.
+		{"value", "0100ff000000000100000000"},
+	}
+	for _, test := range tests {
+		data := mustHex(t, test.data)
+		if _, _, err := DecodeMap(data); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%s length %s: DecodeMap returned %v, want %v", test.name, test.data, err, ErrTooLarge)
+		}
+		// Without a practical size limit, the length is compared
+		// against the remaining data.
+		o := DecodeOptions{MaxValueSize: math.MaxInt}
+		if _, _, err := o.DecodeMap(data); !errors.Is(err, ErrTruncated) && !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%s length %s: DecodeMap without limit returned %v, want %v", test.name, test.data, err, ErrTruncated)
+		}
+		if _, err := Decode(append([]byte(psbtMagic), data...)); err == nil {
+			t.Errorf("%s length %s: Decode succeeded", test.name, test.data)
+		}
+	}
+}
+
+func TestScanPSBTTrailingData(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(testPSBTs[0].base64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ScanPSBT(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	for _, trailing := range [][]byte{{0x00}, data} {
+		in := append(bytes.Clone(data), trailing...)
+		if _, err := ScanPSBT(bytes.NewReader(in)); !errors.Is(err, ErrTrailingData) {
+			t.Errorf("ScanPSBT with %d trailing bytes returned %v, want %v", len(trailing), err, ErrTrailingData)
+		}
+		if _, err := Decode(in); !errors.Is(err, ErrTrailingData) {
+			t.Errorf("Decode with %d trailing bytes returned %v, want %v", len(trailing), err, ErrTrailingData)
+		}
+	}
+}
+
+func TestKeyLength(t *testing.T) {
+	tests := []struct {
+		scope Scope
+		e     Entry
+	}{
+		{ScopeGlobal, Entry{Key: []byte{PSBT_GLOBAL_UNSIGNED_TX, 0x00}}},
+		{ScopeGlobal, Entry{Key: []byte{PSBT_GLOBAL_VERSION, 0x00}, Val: []byte{2, 0, 0, 0}}},
+		{ScopeInput, Entry{Key: []byte{PSBT_IN_WITNESS_UTXO, 0x00}}},
+		{ScopeInput, Entry{Key: []byte{PSBT_IN_SIGHASH_TYPE, 0x00}, Val: []byte{1, 0, 0, 0}}},
+		{ScopeInput, Entry{Key: []byte{PSBT_IN_FINAL_SCRIPTSIG, 0x00}}},
+		{ScopeInput, Entry{Key: []byte{PSBT_IN_TAP_INTERNAL_KEY, 0x00}, Val: make([]byte, 32)}},
+		{ScopeOutput, Entry{Key: []byte{PSBT_OUT_WITNESS_SCRIPT, 0x00}}},
+		{ScopeGlobal, Entry{}},
+		{ScopeInput, Entry{}},
+		{ScopeOutput, Entry{}},
+	}
+	for _, test := range tests {
+		m := Map{test.e}
+		var err error
+		switch test.scope {
+		case ScopeGlobal:
+			_, err = decodeGlobal(m)
+		case ScopeInput:
+			_, err = decodeInput(m)
+		case ScopeOutput:
+			_, err = decodeOutput(m)
+		}
+		if err == nil {
+			t.Errorf("%s key %#x: decoding succeeded", test.scope, test.e.Key)
+		}
+	}
+}
+
+func TestEmptyKey(t *testing.T) {
+	e := Entry{Val: []byte{0x01}}
+	if _, err := DecodeDerivation(e); err == nil {
+		t.Error("DecodeDerivation succeeded")
+	}
+	if _, err := DecodePSBTXpub(e); err == nil {
+		t.Error("DecodePSBTXpub succeeded")
+	}
+	if _, err := DecodeTapScriptSig(e); err == nil {
+		t.Error("DecodeTapScriptSig succeeded")
+	}
+	if _, err := DecodeTapLeafScript(e); err == nil {
+		t.Error("DecodeTapLeafScript succeeded")
+	}
+	if _, err := DecodeTapDerivation(e); err == nil {
+		t.Error("DecodeTapDerivation succeeded")
+	}
+	p := PSBT{
+		Global:  Global{Entries: Map{e}},
+		Inputs:  []Input{{Entries: Map{e}}},
+		Outputs: []Output{{Entries: Map{e}}},
+	}
+	var buf bytes.Buffer
+	Dump(&buf, p)
+	if !bytes.Contains(buf.Bytes(), []byte("empty key")) {
+		t.Errorf("Dump output %q doesn't mention the empty key", buf.String())
+	}
+}
+
+func TestDecodeHugeCounts(t *testing.T) {
+	// A version 2 global map declaring 2^31-1 inputs and
+	// 2^31-1 outputs, whose sum overflows a 32-bit int.
+	data := mustHex(t, "01020402000000010405feffffff7f010505feffffff7f01fb040200000000")
+	if _, err := Decode(append([]byte(psbtMagic), data...)); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Decode returned %v, want %v", err, ErrTruncated)
+	}
+}
@@ -0,0 +1,89 @@
+// Package psbttest provides utilities for testing code that
+// handles PSBTs.
+package psbttest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/seedhammer/bip-serialized-descriptors/psbt"
+)
+
+// Diff returns a description of the differences between the maps of
+// a and b, one per line, such as "input[1] PSBT_IN_WITNESS_UTXO value
+// mismatch". Field types are named by psbt.KeyTypeName. Entries are
+// compared by their full key, regardless of order. The empty string
+// is returned if a and b have the same entries.
+func Diff(a, b psbt.PSBT) string {
+	var diffs []string
+	diffs = diffMap(diffs, "global", psbt.ScopeGlobal, a.Global.Entries, b.Global.Entries)
+	diffs = diffMaps(diffs, psbt.ScopeInput, inputMaps(a.Inputs), inputMaps(b.Inputs))
+	diffs = diffMaps(diffs, psbt.ScopeOutput, outputMaps(a.Outputs), outputMaps(b.Outputs))
+	return strings.Join(diffs, "\n")
+}
+
+func inputMaps(inputs []psbt.Input) []psbt.Map {
+	maps := make([]psbt.Map, len(inputs))
+	for i, in := range inputs {
+		maps[i] = in.Entries
+	}
+	return maps
+}
+
+func outputMaps(outputs []psbt.Output) []psbt.Map {
+	maps := make([]psbt.Map, len(outputs))
+	for i, out := range outputs {
+		maps[i] = out.Entries
+	}
+	return maps
+}
+
+func diffMaps(diffs []string, scope psbt.Scope, a, b []psbt.Map) []string {
+	if len(a) != len(b) {
+		diffs = append(diffs, fmt.Sprintf("%s count %d != %d", scope, len(a), len(b)))
+	}
+	for i := 0; i < min(len(a), len(b)); i++ {
+		diffs = diffMap(diffs, fmt.Sprintf("%s[%d]", scope, i), scope, a[i], b[i])
+	}
+	return diffs
+}
+
+func diffMap(diffs []string, name string, scope psbt.Scope, a, b psbt.Map) []string {
+	for _, ea := range a {
+		eb, ok := find(b, ea.Key)
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s %s missing in b", name, describeKey(scope, ea.Key)))
+		case !bytes.Equal(ea.Val, eb.Val):
+			diffs = append(diffs, fmt.Sprintf("%s %s value mismatch", name, describeKey(scope, ea.Key)))
+		}
+	}
+	for _, eb := range b {
+		if _, ok := find(a, eb.Key); !ok {
+			diffs = append(diffs, fmt.Sprintf("%s %s missing in a", name, describeKey(scope, eb.Key)))
+		}
+	}
+	return diffs
+}
+
+func find(m psbt.Map, key []byte) (psbt.Entry, bool) {
+	for _, e := range m {
+		if bytes.Equal(e.Key, key) {
+			return e, true
+		}
+	}
+	return psbt.Entry{}, false
+}
+
+// describeKey formats the key type and any key data of a key.
+func describeKey(scope psbt.Scope, key []byte) string {
+	if len(key) == 0 {
+		return "empty key"
+	}
+	name := psbt.KeyTypeName(scope, key[0])
+	if len(key) == 1 {
+		return name
+	}
+	return fmt.Sprintf("%s (key data %x)", name, key[1:])
+}
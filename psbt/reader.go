@@ -0,0 +1,165 @@
+package psbt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// This file implements decoding from streams, for input that
+// arrives incrementally.
+
+// DecodeReader decodes a PSBT from r with the default options.
+func DecodeReader(r io.Reader) (PSBT, error) {
+	return DecodeOptions{}.DecodeReader(r)
+}
+
+// DecodeReaderContext decodes a PSBT from r with the default options,
+// stopping early if ctx is done.
+func DecodeReaderContext(ctx context.Context, r io.Reader) (PSBT, error) {
+	return DecodeOptions{}.DecodeReaderContext(ctx, r)
+}
+
+// DecodeReader decodes a PSBT from r. Reading stops after the
+// last output map, but r may be read beyond that point because
+// of buffering.
+func (o DecodeOptions) DecodeReader(r io.Reader) (PSBT, error) {
+	return o.DecodeReaderContext(context.Background(), r)
+}
+
+// DecodeReaderContext is like DecodeReader, but checks ctx before
+// reading each map and returns its error, along with the number of
+// bytes read, if ctx is done. A read that blocks is not interrupted;
+// to abort one, r must itself observe the cancellation, for example by
+// closing the underlying connection.
+func (o DecodeOptions) DecodeReaderContext(ctx context.Context, r io.Reader) (PSBT, error) {
+	if err := ctx.Err(); err != nil {
+		return PSBT{}, fmt.Errorf("psbt: %w after 0 bytes", err)
+	}
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(psbtMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return PSBT{}, fmt.Errorf("psbt: %w", ErrInvalidMagic)
+		}
+		return PSBT{}, fmt.Errorf("psbt: %w", err)
+	}
+	if string(magic) != psbtMagic {
+		return PSBT{}, fmt.Errorf("psbt: %w", ErrInvalidMagic)
+	}
+	return o.decode(func(off int) (Map, int, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, fmt.Errorf("%w after %d bytes", err, off)
+		}
+		m, n, err := o.readMap(br, off)
+		if err == nil && off+n > o.maxSize() {
+			err = fmt.Errorf("%w: %d bytes", ErrTooLarge, off+n)
+		}
+		return m, n, err
+	})
+}
+
+// ReadMap reads a map with the default options.
+func ReadMap(r *bufio.Reader) (Map, int, error) {
+	return DecodeOptions{}.ReadMap(r)
+}
+
+// ReadMap reads a map from r and returns its entries and the number
+// of bytes read. Reaching the end of r before the first byte of the
+// map results in zero bytes read and no error.
+func (o DecodeOptions) ReadMap(r *bufio.Reader) (Map, int, error) {
+	return o.readMap(r, 0)
+}
+
+// readMap is like ReadMap, but reports errors relative to the
+// offset of the map in a larger input.
+func (o DecodeOptions) readMap(r *bufio.Reader, off int) (Map, int, error) {
+	var m Map
+	seen := make(map[string]bool)
+	n := 0
+	for {
+		if n > o.maxSize() {
+			return nil, n, fmt.Errorf("%w: map at offset %d exceeds %d bytes", ErrTooLarge, off, o.maxSize())
+		}
+		entryOff := off + n
+		keyLen, n1, err := readVarInt(r)
+		n += n1
+		if err != nil {
+			if n == 0 && errors.Is(err, io.EOF) {
+				return nil, 0, nil
+			}
+			return nil, n, fmt.Errorf("%w: key length at offset %d", truncated(err), entryOff)
+		}
+		if keyLen == 0 {
+			// End of map.
+			return m, n, nil
+		}
+		key, n2, err := o.readBytes(r, keyLen)
+		n += n2
+		if err != nil {
+			return nil, n, fmt.Errorf("%w: key at offset %d", err, entryOff+n1)
+		}
+		valOff := off + n
+		valLen, n3, err := readVarInt(r)
+		n += n3
+		if err != nil {
+			return nil, n, fmt.Errorf("%w: value length at offset %d", truncated(err), valOff)
+		}
+		val, n4, err := o.readBytes(r, valLen)
+		n += n4
+		if err != nil {
+			return nil, n, fmt.Errorf("%w: value at offset %d", err, valOff)
+		}
+		m, err = addEntry(m, seen, key, val)
+		if err != nil {
+			return nil, n, fmt.Errorf("%w at offset %d", err, entryOff)
+		}
+	}
+}
+
+// readBytes reads a key or value of length l.
+func (o DecodeOptions) readBytes(r io.Reader, l uint64) ([]byte, int, error) {
+	if l > uint64(o.maxValueSize()) {
+		return nil, 0, fmt.Errorf("%w: length %d", ErrTooLarge, l)
+	}
+	buf := make([]byte, l)
+	n, err := io.ReadFull(r, buf)
+	if err != nil {
+		return nil, n, truncated(err)
+	}
+	return buf, n, nil
+}
+
+// readVarInt reads a variable length integer from r.
+func readVarInt(r *bufio.Reader) (uint64, int, error) {
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	var buf [9]byte
+	buf[0] = prefix
+	l := 1
+	switch prefix {
+	case 0xfd:
+		l = 3
+	case 0xfe:
+		l = 5
+	case 0xff:
+		l = 9
+	}
+	n, err := io.ReadFull(r, buf[1:l])
+	if err != nil {
+		return 0, 1 + n, truncated(err)
+	}
+	return decodeVarInt(buf[:l])
+}
+
+// truncated converts end of input errors to ErrTruncated.
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrTruncated
+	}
+	return err
+}
@@ -0,0 +1,178 @@
+package psbt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Script is a Bitcoin script, such as an output script or the
+// redeem or witness script of a P2SH or P2WSH output.
+type Script []byte
+
+// Opcodes of the standard output scripts.
+const (
+	OP_0             = 0x00
+	OP_1             = 0x51
+	OP_16            = 0x60
+	OP_DUP           = 0x76
+	OP_EQUAL         = 0x87
+	OP_EQUALVERIFY   = 0x88
+	OP_HASH160       = 0xa9
+	OP_CHECKSIG      = 0xac
+	OP_CHECKMULTISIG = 0xae
+)
+
+// maxMultisigKeys is the maximum number of keys of OP_CHECKMULTISIG.
+const maxMultisigKeys = 20
+
+// MultiScript returns the script of a threshold-of-n multisig with the
+// public keys in the given order, as described by the multi descriptor
+// function.
+func MultiScript(threshold int, pubkeys [][]byte) (Script, error) {
+	n := len(pubkeys)
+	if n == 0 || n > maxMultisigKeys {
+		return nil, fmt.Errorf("psbt: invalid multisig key count %d", n)
+	}
+	if threshold < 1 || threshold > n {
+		return nil, fmt.Errorf("psbt: multisig threshold %d out of range for %d keys", threshold, n)
+	}
+	var s Script
+	s = s.pushInt(threshold)
+	for i, pk := range pubkeys {
+		if len(pk) != compressedPubKeyLen {
+			return nil, fmt.Errorf("psbt: multisig key %d: invalid length %d", i, len(pk))
+		}
+		s = s.push(pk)
+	}
+	s = s.pushInt(n)
+	return append(s, OP_CHECKMULTISIG), nil
+}
+
+// SortedMultiScript is like MultiScript but sorts the keys
+// lexicographically as specified by BIP-67 and the sortedmulti
+// descriptor function.
+func SortedMultiScript(threshold int, pubkeys [][]byte) (Script, error) {
+	sorted := slices.Clone(pubkeys)
+	slices.SortFunc(sorted, bytes.Compare)
+	return MultiScript(threshold, sorted)
+}
+
+// PKScript returns the pay-to-pubkey script of a compressed public key.
+func PKScript(pubkey []byte) Script {
+	return append(Script(nil).push(pubkey), OP_CHECKSIG)
+}
+
+// P2PKHScript returns the pay-to-pubkey-hash script of a
+// compressed public key.
+func P2PKHScript(pubkey []byte) Script {
+	s := Script{OP_DUP, OP_HASH160}.push(hash160(pubkey))
+	return append(s, OP_EQUALVERIFY, OP_CHECKSIG)
+}
+
+// P2WPKHScript returns the version 0 witness output script of a
+// compressed public key.
+func P2WPKHScript(pubkey []byte) Script {
+	return Script{OP_0}.push(hash160(pubkey))
+}
+
+// P2TRScript returns the version 1 witness output script of a
+// 32 byte taproot output key.
+func P2TRScript(outputKey []byte) Script {
+	return Script{OP_1}.push(outputKey)
+}
+
+// P2SH returns the pay-to-script-hash output script with s as
+// its redeem script.
+func (s Script) P2SH() Script {
+	return append(Script{OP_HASH160}.push(hash160(s)), OP_EQUAL)
+}
+
+// P2WSH returns the version 0 witness output script with s as
+// its witness script.
+func (s Script) P2WSH() Script {
+	h := sha256.Sum256(s)
+	return Script{OP_0}.push(h[:])
+}
+
+// ErrNonStandardScript is returned for output scripts that are not
+// of a standard type.
+var ErrNonStandardScript = errors.New("non-standard script")
+
+// WitnessProgram returns the version and program of a witness
+// output script.
+func (s Script) WitnessProgram() (byte, []byte, error) {
+	if len(s) < 4 || len(s) > 42 || int(s[1]) != len(s)-2 {
+		return 0, nil, fmt.Errorf("psbt: %w", ErrNonStandardScript)
+	}
+	switch v := s[0]; {
+	case v == OP_0:
+		return 0, s[2:], nil
+	case OP_1 <= v && v <= OP_16:
+		return v - OP_1 + 1, s[2:], nil
+	default:
+		return 0, nil, fmt.Errorf("psbt: %w", ErrNonStandardScript)
+	}
+}
+
+// Multisig returns the threshold and public keys of a script in the
+// form returned by MultiScript.
+func (s Script) Multisig() (int, [][]byte, error) {
+	if len(s) < 3 || s[len(s)-1] != OP_CHECKMULTISIG {
+		return 0, nil, fmt.Errorf("psbt: %w: not a multisig script", ErrNonStandardScript)
+	}
+	body := s[:len(s)-1]
+	k, klen := scriptInt(body)
+	// The key count is pushed as an opcode or, above 16, as a
+	// single byte.
+	n, nlen := scriptInt(body[len(body)-1:])
+	if nlen == 0 && len(body) >= 2 {
+		if n, nlen = scriptInt(body[len(body)-2:]); nlen != 2 {
+			nlen = 0
+		}
+	}
+	if klen == 0 || nlen == 0 || klen+nlen > len(body) || n < k || n > maxMultisigKeys {
+		return 0, nil, fmt.Errorf("psbt: %w: invalid multisig counts", ErrNonStandardScript)
+	}
+	keys := body[klen : len(body)-nlen]
+	var pubkeys [][]byte
+	for len(keys) > 0 {
+		if keys[0] != compressedPubKeyLen || len(keys) < 1+compressedPubKeyLen {
+			return 0, nil, fmt.Errorf("psbt: %w: invalid multisig key", ErrNonStandardScript)
+		}
+		pubkeys = append(pubkeys, keys[1:1+compressedPubKeyLen])
+		keys = keys[1+compressedPubKeyLen:]
+	}
+	if len(pubkeys) != n {
+		return 0, nil, fmt.Errorf("psbt: %w: %d keys for count %d", ErrNonStandardScript, len(pubkeys), n)
+	}
+	return k, pubkeys, nil
+}
+
+// scriptInt decodes the push of a multisig count at the start of s,
+// in the form appended by pushInt. It returns the count and the
+// length of the push, or zero length if s doesn't start with one.
+func scriptInt(s Script) (int, int) {
+	switch {
+	case len(s) >= 1 && OP_1 <= s[0] && s[0] <= OP_16:
+		return int(s[0]) - OP_1 + 1, 1
+	case len(s) >= 2 && s[0] == 1 && 16 < s[1] && s[1] <= maxMultisigKeys:
+		return int(s[1]), 2
+	}
+	return 0, 0
+}
+
+// pushInt appends the push of a small integer to s.
+func (s Script) pushInt(n int) Script {
+	if n <= 16 {
+		return append(s, byte(OP_1-1+n))
+	}
+	return s.push([]byte{byte(n)})
+}
+
+// push appends the push of data shorter than 76 bytes to s.
+func (s Script) push(data []byte) Script {
+	return append(append(s, byte(len(data))), data...)
+}
@@ -0,0 +1,58 @@
+package psbt
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestMultisigRoundTrip(t *testing.T) {
+	var pubkeys [][]byte
+	for i := 0; i < maxMultisigKeys; i++ {
+		pk := bytes.Repeat([]byte{byte(i)}, compressedPubKeyLen)
+		pk[0] = 0x02
+		pubkeys = append(pubkeys, pk)
+	}
+	for n := 1; n <= maxMultisigKeys; n++ {
+		for _, threshold := range []int{1, (n + 1) / 2, n} {
+			s, err := MultiScript(threshold, pubkeys[:n])
+			if err != nil {
+				t.Fatalf("MultiScript(%d, %d keys): %v", threshold, n, err)
+			}
+			k, keys, err := s.Multisig()
+			if err != nil {
+				t.Errorf("%d-of-%d: Multisig: %v", threshold, n, err)
+				continue
+			}
+			if k != threshold || !slices.EqualFunc(keys, pubkeys[:n], bytes.Equal) {
+				t.Errorf("%d-of-%d: Multisig returned %d-of-%d", threshold, n, k, len(keys))
+			}
+		}
+	}
+	if _, err := MultiScript(1, append(pubkeys, pubkeys[0])); err == nil {
+		t.Errorf("MultiScript with %d keys succeeded", maxMultisigKeys+1)
+	}
+}
+
+func TestMultisigInvalid(t *testing.T) {
+	pk := append([]byte{compressedPubKeyLen, 0x02}, make([]byte, compressedPubKeyLen-1)...)
+	script := func(k, n []byte) Script {
+		return append(append(append(Script{}, k...), pk...), n...)
+	}
+	tests := []struct {
+		name   string
+		script Script
+	}{
+		{"counts only", Script{OP_1, OP_1, OP_CHECKMULTISIG}},
+		{"threshold above count", script([]byte{OP_1 + 1}, []byte{OP_1, OP_CHECKMULTISIG})},
+		{"key count mismatch", script([]byte{OP_1}, []byte{OP_1 + 1, OP_CHECKMULTISIG})},
+		{"non-minimal count", script([]byte{OP_1}, []byte{1, 1, OP_CHECKMULTISIG})},
+		{"count above limit", script([]byte{OP_1}, []byte{1, maxMultisigKeys + 1, OP_CHECKMULTISIG})},
+		{"missing checkmultisig", script([]byte{OP_1}, []byte{OP_1})},
+	}
+	for _, test := range tests {
+		if _, _, err := test.script.Multisig(); err == nil {
+			t.Errorf("%s: Multisig succeeded", test.name)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package psbt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SighashType is the signature hash type requested for an input
+// by PSBT_IN_SIGHASH_TYPE.
+type SighashType uint32
+
+const (
+	// SighashDefault is the BIP-341 default, with the semantics of
+	// SighashAll. It is only valid for taproot inputs, and is the
+	// value of taproot inputs without a PSBT_IN_SIGHASH_TYPE field.
+	SighashDefault      SighashType = 0x00
+	SighashAll          SighashType = 0x01
+	SighashNone         SighashType = 0x02
+	SighashSingle       SighashType = 0x03
+	SighashAnyoneCanPay SighashType = 0x80
+)
+
+// ErrUnknownSighash is returned by decoding with StrictSighash
+// for sighash types that aren't a known flag combination.
+var ErrUnknownSighash = errors.New("unknown sighash type")
+
+// Known reports whether t is SighashDefault or one of SighashAll,
+// SighashNone and SighashSingle, optionally combined with
+// SighashAnyoneCanPay. SighashDefault is only known to taproot
+// inputs, which decoding with StrictSighash checks.
+func (t SighashType) Known() bool {
+	switch t &^ SighashAnyoneCanPay {
+	case SighashAll, SighashNone, SighashSingle:
+		return true
+	}
+	return t == SighashDefault
+}
+
+// knownSighash reports whether the sighash type of in is known
+// for its kind of input.
+func (in Input) knownSighash() bool {
+	if in.SighashType == SighashDefault && !in.Taproot() {
+		return false
+	}
+	return in.SighashType.Known()
+}
+
+// Taproot reports whether in spends a taproot output, that is
+// whether it has BIP-371 fields or a witness utxo with a
+// version 1 witness program.
+func (in Input) Taproot() bool {
+	if in.TapKeySig != nil || in.TapScriptSigs != nil || in.TapLeafScripts != nil ||
+		in.TapDerivations != nil || in.TapInternalKey != nil {
+		return true
+	}
+	if in.WitnessUTXO == nil {
+		return false
+	}
+	v, prog, err := Script(in.WitnessUTXO.ScriptPubKey).WitnessProgram()
+	return err == nil && v == 1 && len(prog) == xOnlyPubKeyLen
+}
+
+// AnyoneCanPay reports whether t has the SighashAnyoneCanPay flag.
+func (t SighashType) AnyoneCanPay() bool {
+	return t&SighashAnyoneCanPay != 0
+}
+
+func (t SighashType) String() string {
+	if !t.Known() {
+		return fmt.Sprintf("SIGHASH_UNKNOWN(%#x)", uint32(t))
+	}
+	var s string
+	switch t &^ SighashAnyoneCanPay {
+	case SighashDefault:
+		return "SIGHASH_DEFAULT"
+	case SighashAll:
+		s = "SIGHASH_ALL"
+	case SighashNone:
+		s = "SIGHASH_NONE"
+	case SighashSingle:
+		s = "SIGHASH_SINGLE"
+	}
+	if t.AnyoneCanPay() {
+		s += "|SIGHASH_ANYONECANPAY"
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package psbt
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestSighashTypePresence(t *testing.T) {
+	sighash := func(v byte) Entry {
+		return Entry{Key: []byte{PSBT_IN_SIGHASH_TYPE}, Val: []byte{v, 0, 0, 0}}
+	}
+	internalKey := Entry{Key: []byte{PSBT_IN_TAP_INTERNAL_KEY}, Val: make([]byte, xOnlyPubKeyLen)}
+	tests := []struct {
+		name  string
+		m     Map
+		has   bool
+		want  SighashType
+		known bool
+	}{
+		{"absent", Map{}, false, SighashAll, true},
+		{"absent taproot", Map{internalKey}, false, SighashDefault, true},
+		{"explicit default", Map{sighash(0)}, true, SighashDefault, false},
+		{"explicit taproot default", Map{internalKey, sighash(0)}, true, SighashDefault, true},
+		{"all", Map{sighash(1)}, true, SighashAll, true},
+	}
+	for _, test := range tests {
+		in, err := decodeInput(test.m)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if in.HasSighashType != test.has || in.SighashType != test.want {
+			t.Errorf("%s: sighash type %s, present %v, want %s, %v", test.name, in.SighashType, in.HasSighashType, test.want, test.has)
+		}
+		if got := in.knownSighash(); got != test.known {
+			t.Errorf("%s: known sighash %v, want %v", test.name, got, test.known)
+		}
+	}
+}
+
+func TestStrictSighashDefault(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(testPSBTs[3].base64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Inputs[0].Entries = append(p.Inputs[0].Entries, Entry{Key: []byte{PSBT_IN_SIGHASH_TYPE}, Val: []byte{0, 0, 0, 0}})
+	data, err = Encode(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(data); err != nil {
+		t.Errorf("Decode: %v", err)
+	}
+	strict := DecodeOptions{StrictSighash: true}
+	if _, err := strict.Decode(data); !errors.Is(err, ErrUnknownSighash) {
+		t.Errorf("strict Decode of SIGHASH_DEFAULT for a non-taproot input returned %v, want %v", err, ErrUnknownSighash)
+	}
+}
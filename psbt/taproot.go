@@ -0,0 +1,110 @@
+package psbt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// This file implements decoding of the BIP-371 taproot input fields.
+
+// xOnlyPubKeyLen is the length of a BIP-340 x-only public key.
+const xOnlyPubKeyLen = 32
+
+// leafHashLen is the length of a tapleaf hash.
+const leafHashLen = 32
+
+// validSchnorrSigLen reports whether n is the length of a BIP-340
+// signature, optionally followed by a sighash type.
+func validSchnorrSigLen(n int) bool {
+	return n == 64 || n == 65
+}
+
+// TapScriptSig is a signature for a leaf script of a taproot input.
+type TapScriptSig struct {
+	// PubKey is the x-only public key of the signature.
+	PubKey []byte
+	// LeafHash is the hash of the signed leaf.
+	LeafHash []byte
+	// Signature is the BIP-340 signature, optionally followed
+	// by the sighash type byte.
+	Signature []byte
+}
+
+// DecodeTapScriptSig decodes a PSBT_IN_TAP_SCRIPT_SIG entry.
+func DecodeTapScriptSig(e Entry) (TapScriptSig, error) {
+	key := keyData(e)
+	if len(key) != xOnlyPubKeyLen+leafHashLen {
+		return TapScriptSig{}, fmt.Errorf("invalid key length %d", len(key))
+	}
+	if !validSchnorrSigLen(len(e.Val)) {
+		return TapScriptSig{}, fmt.Errorf("invalid signature length %d", len(e.Val))
+	}
+	return TapScriptSig{
+		PubKey:    key[:xOnlyPubKeyLen],
+		LeafHash:  key[xOnlyPubKeyLen:],
+		Signature: e.Val,
+	}, nil
+}
+
+// TapLeafScript is a leaf script of a taproot input.
+type TapLeafScript struct {
+	// ControlBlock proves the inclusion of the script
+	// in the output key.
+	ControlBlock []byte
+	Script       []byte
+	LeafVersion  uint8
+}
+
+// DecodeTapLeafScript decodes a PSBT_IN_TAP_LEAF_SCRIPT entry.
+func DecodeTapLeafScript(e Entry) (TapLeafScript, error) {
+	cb := keyData(e)
+	// The control block is the leaf version and parity byte, the
+	// internal key and up to 128 merkle path hashes.
+	if len(cb) < 1+xOnlyPubKeyLen || (len(cb)-1-xOnlyPubKeyLen)%32 != 0 ||
+		len(cb) > 1+xOnlyPubKeyLen+128*32 {
+		return TapLeafScript{}, fmt.Errorf("invalid control block length %d", len(cb))
+	}
+	if len(e.Val) == 0 {
+		return TapLeafScript{}, errors.New("missing leaf version")
+	}
+	return TapLeafScript{
+		ControlBlock: cb,
+		Script:       e.Val[:len(e.Val)-1],
+		LeafVersion:  e.Val[len(e.Val)-1],
+	}, nil
+}
+
+// TapDerivation is the BIP-32 origin of an x-only public key
+// along with the leaves it is used in.
+type TapDerivation struct {
+	PubKey     []byte
+	LeafHashes [][]byte
+	ExtendedKey
+}
+
+// DecodeTapDerivation decodes a PSBT_IN_TAP_BIP32_DERIVATION entry,
+// whose value holds the leaf hashes before the key origin.
+func DecodeTapDerivation(e Entry) (TapDerivation, error) {
+	pub := keyData(e)
+	if len(pub) != xOnlyPubKeyLen {
+		return TapDerivation{}, fmt.Errorf("invalid public key length %d", len(pub))
+	}
+	nhashes, n, err := decodeVarInt(e.Val)
+	if err != nil {
+		return TapDerivation{}, err
+	}
+	val := e.Val[n:]
+	if nhashes > uint64(len(val)/leafHashLen) {
+		return TapDerivation{}, ErrTruncated
+	}
+	d := TapDerivation{PubKey: pub}
+	for i := uint64(0); i < nhashes; i++ {
+		d.LeafHashes = append(d.LeafHashes, val[:leafHashLen])
+		val = val[leafHashLen:]
+	}
+	d.MasterFingerprint, d.Path, err = decodeOrigin(val)
+	if err != nil {
+		return TapDerivation{}, err
+	}
+	return d, nil
+}
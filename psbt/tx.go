@@ -0,0 +1,185 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Transaction is a transaction in the legacy serialization
+// without witnesses, as found in PSBT_GLOBAL_UNSIGNED_TX.
+type Transaction struct {
+	Version  uint32
+	Inputs   []TxIn
+	Outputs  []TxOut
+	LockTime uint32
+}
+
+// TxIn is a transaction input.
+type TxIn struct {
+	// PrevTxID is the id of the previous transaction in
+	// serialized (little-endian) byte order.
+	PrevTxID  [32]byte
+	PrevIndex uint32
+	ScriptSig []byte
+	Sequence  uint32
+}
+
+// TxOut is a transaction output.
+type TxOut struct {
+	// Value is the amount in satoshis.
+	Value        int64
+	ScriptPubKey []byte
+}
+
+// DecodeTransaction decodes a transaction serialized without witnesses.
+func DecodeTransaction(data []byte) (Transaction, error) {
+	bo := binary.LittleEndian
+	var tx Transaction
+	if len(data) < 4 {
+		return Transaction{}, ErrTruncated
+	}
+	tx.Version = bo.Uint32(data)
+	data = data[4:]
+	nin, n, err := decodeVarInt(data)
+	if err != nil {
+		return Transaction{}, err
+	}
+	data = data[n:]
+	// Every input is at least 41 bytes.
+	if nin > uint64(len(data)/41) {
+		return Transaction{}, ErrTruncated
+	}
+	for i := uint64(0); i < nin; i++ {
+		var in TxIn
+		if len(data) < 32+4 {
+			return Transaction{}, ErrTruncated
+		}
+		copy(in.PrevTxID[:], data)
+		in.PrevIndex = bo.Uint32(data[32:])
+		data = data[32+4:]
+		script, n, err := decodeBytes(data)
+		data = data[n:]
+		if err != nil {
+			return Transaction{}, fmt.Errorf("input %d: %w", i, err)
+		}
+		in.ScriptSig = script
+		if len(data) < 4 {
+			return Transaction{}, ErrTruncated
+		}
+		in.Sequence = bo.Uint32(data)
+		data = data[4:]
+		tx.Inputs = append(tx.Inputs, in)
+	}
+	nout, n, err := decodeVarInt(data)
+	if err != nil {
+		return Transaction{}, err
+	}
+	data = data[n:]
+	// Every output is at least 9 bytes.
+	if nout > uint64(len(data)/9) {
+		return Transaction{}, ErrTruncated
+	}
+	for i := uint64(0); i < nout; i++ {
+		out, n, err := decodeTxOut(data)
+		data = data[n:]
+		if err != nil {
+			return Transaction{}, fmt.Errorf("output %d: %w", i, err)
+		}
+		tx.Outputs = append(tx.Outputs, out)
+	}
+	if len(data) < 4 {
+		return Transaction{}, ErrTruncated
+	}
+	tx.LockTime = bo.Uint32(data)
+	data = data[4:]
+	if len(data) > 0 {
+		return Transaction{}, errors.New("trailing data after transaction")
+	}
+	return tx, nil
+}
+
+// hasWitnessMarker reports whether data starts with a version
+// followed by the segwit marker and flag. Without witnesses, the
+// same bytes would encode a transaction without inputs.
+func hasWitnessMarker(data []byte) bool {
+	return len(data) >= 6 && data[4] == 0x00 && data[5] == 0x01
+}
+
+// Encode returns the serialization of tx. If witnesses is not nil,
+// it holds the serialized witness of every input and tx is
+// serialized with the segwit marker and flag.
+func (tx Transaction) Encode(witnesses [][]byte) []byte {
+	bo := binary.LittleEndian
+	w := new(bytes.Buffer)
+	w.Write(bo.AppendUint32(nil, tx.Version))
+	if witnesses != nil {
+		w.Write([]byte{0x00, 0x01})
+	}
+	WriteVarInt(w, uint64(len(tx.Inputs)))
+	for _, in := range tx.Inputs {
+		w.Write(in.PrevTxID[:])
+		w.Write(bo.AppendUint32(nil, in.PrevIndex))
+		WriteVarInt(w, uint64(len(in.ScriptSig)))
+		w.Write(in.ScriptSig)
+		w.Write(bo.AppendUint32(nil, in.Sequence))
+	}
+	WriteVarInt(w, uint64(len(tx.Outputs)))
+	for _, out := range tx.Outputs {
+		w.Write(bo.AppendUint64(nil, uint64(out.Value)))
+		WriteVarInt(w, uint64(len(out.ScriptPubKey)))
+		w.Write(out.ScriptPubKey)
+	}
+	for _, wit := range witnesses {
+		if len(wit) == 0 {
+			// Empty witness stack.
+			w.WriteByte(0)
+			continue
+		}
+		w.Write(wit)
+	}
+	w.Write(bo.AppendUint32(nil, tx.LockTime))
+	return w.Bytes()
+}
+
+// DecodeTxOut decodes a serialized transaction output, as found
+// in PSBT_IN_WITNESS_UTXO.
+func DecodeTxOut(data []byte) (TxOut, error) {
+	out, n, err := decodeTxOut(data)
+	if err != nil {
+		return TxOut{}, err
+	}
+	if n != len(data) {
+		return TxOut{}, errors.New("trailing data after output")
+	}
+	return out, nil
+}
+
+func decodeTxOut(data []byte) (TxOut, int, error) {
+	if len(data) < 8 {
+		return TxOut{}, 0, ErrTruncated
+	}
+	out := TxOut{
+		Value: int64(binary.LittleEndian.Uint64(data)),
+	}
+	script, n, err := decodeBytes(data[8:])
+	if err != nil {
+		return TxOut{}, 0, err
+	}
+	out.ScriptPubKey = script
+	return out, 8 + n, nil
+}
+
+// decodeBytes decodes a length-prefixed byte string.
+func decodeBytes(data []byte) ([]byte, int, error) {
+	l, n, err := decodeVarInt(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	data = data[n:]
+	if l > uint64(len(data)) {
+		return nil, 0, ErrTruncated
+	}
+	return data[:l], n + int(l), nil
+}
@@ -0,0 +1,103 @@
+package ur
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// This file implements the minimal Bytewords encoding of BCR-2020-012,
+// where each byte is represented by the first and last letters of
+// its word, followed by a CRC32 checksum.
+
+var bytewords = [256]string{
+	"able", "acid", "also", "apex", "aqua", "arch", "atom", "aunt",
+	"away", "axis", "back", "bald", "barn", "belt", "beta", "bias",
+	"blue", "body", "brag", "brew", "bulb", "buzz", "calm", "cash",
+	"cats", "chef", "city", "claw", "code", "cola", "cook", "cost",
+	"crux", "curl", "cusp", "cyan", "dark", "data", "days", "deli",
+	"dice", "diet", "door", "down", "draw", "drop", "drum", "dull",
+	"duty", "each", "easy", "echo", "edge", "epic", "even", "exam",
+	"exit", "eyes", "fact", "fair", "fern", "figs", "film", "fish",
+	"fizz", "flap", "flew", "flux", "foxy", "free", "frog", "fuel",
+	"fund", "gala", "game", "gear", "gems", "gift", "girl", "glow",
+	"good", "gray", "grim", "guru", "gush", "gyro", "half", "hang",
+	"hard", "hawk", "heat", "help", "high", "hill", "holy", "hope",
+	"horn", "huts", "iced", "idea", "idle", "inch", "inky", "into",
+	"iris", "iron", "item", "jade", "jazz", "join", "jolt", "jowl",
+	"judo", "jugs", "jump", "junk", "jury", "keep", "keno", "kept",
+	"keys", "kick", "kiln", "king", "kite", "kiwi", "knob", "lamb",
+	"lava", "lazy", "leaf", "legs", "liar", "limp", "lion", "list",
+	"logo", "loud", "love", "luau", "luck", "lung", "main", "many",
+	"math", "maze", "memo", "menu", "meow", "mild", "mint", "miss",
+	"monk", "nail", "navy", "need", "news", "next", "noon", "note",
+	"numb", "obey", "oboe", "omit", "onyx", "open", "oval", "owls",
+	"paid", "part", "peck", "play", "plus", "poem", "pool", "pose",
+	"puff", "puma", "purr", "quad", "quiz", "race", "ramp", "real",
+	"redo", "rich", "road", "rock", "roof", "ruby", "ruin", "runs",
+	"rust", "safe", "saga", "scar", "sets", "silk", "skew", "slot",
+	"soap", "solo", "song", "stub", "surf", "swan", "taco", "task",
+	"taxi", "tent", "tied", "time", "tiny", "toil", "tomb", "toys",
+	"trip", "tuna", "twin", "ugly", "undo", "unit", "urge", "user",
+	"vast", "very", "veto", "vial", "vibe", "view", "visa", "void",
+	"vows", "wall", "wand", "warm", "wasp", "wave", "waxy", "webs",
+	"what", "when", "whiz", "wolf", "work", "yank", "yawn", "yell",
+	"yoga", "yurt", "zaps", "zero", "zest", "zinc", "zone", "zoom",
+}
+
+// minimalIndex maps the two letter minimal form of a word to its byte.
+var minimalIndex = func() map[string]byte {
+	m := make(map[string]byte, len(bytewords))
+	for i, w := range bytewords {
+		m[w[:1]+w[3:]] = byte(i)
+	}
+	return m
+}()
+
+// ErrChecksum is returned for Bytewords with an invalid checksum.
+var ErrChecksum = errors.New("ur: invalid checksum")
+
+// encodeBytewords returns the minimal Bytewords encoding of data,
+// including its checksum.
+func encodeBytewords(data []byte) string {
+	data = binary.BigEndian.AppendUint32(data[:len(data):len(data)], crc32.ChecksumIEEE(data))
+	b := make([]byte, 0, 2*len(data))
+	for _, d := range data {
+		w := bytewords[d]
+		b = append(b, w[0], w[3])
+	}
+	return string(b)
+}
+
+// decodeBytewords decodes minimal Bytewords, verifying and
+// removing the checksum. Upper case input is accepted.
+func decodeBytewords(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		return nil, errors.New("ur: odd length bytewords")
+	}
+	data := make([]byte, 0, len(s)/2)
+	for i := 0; i < len(s); i += 2 {
+		w := []byte{lower(s[i]), lower(s[i+1])}
+		b, ok := minimalIndex[string(w)]
+		if !ok {
+			return nil, fmt.Errorf("ur: invalid byteword %q", s[i:i+2])
+		}
+		data = append(data, b)
+	}
+	if len(data) < 4 {
+		return nil, errors.New("ur: bytewords too short")
+	}
+	data, sum := data[:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(sum) {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
+
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
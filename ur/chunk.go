@@ -0,0 +1,104 @@
+package ur
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// This file implements a simple alternative to multi-part URs for
+// transfers where every frame is scanned: the data is split into a
+// fixed sequence of frames, each with a header carrying the frame
+// index, the number of frames and the checksum of the data.
+
+// ChunkVersion is the version of the frame header.
+const ChunkVersion = 1
+
+// ChunkHeaderLen is the length of the frame header: the version (1 byte),
+// the frame index (2 bytes), the number of frames (2 bytes) and the
+// CRC32 checksum of the data (4 bytes). Integers are big endian.
+const ChunkHeaderLen = 1 + 2 + 2 + 4
+
+// maxChunks is the maximum number of frames.
+const maxChunks = 0xffff
+
+// ChunkBytes splits data into frames of at most maxFrame bytes,
+// header included. It panics if maxFrame is not larger than
+// ChunkHeaderLen or if more than 65535 frames are needed.
+func ChunkBytes(data []byte, maxFrame int) [][]byte {
+	if maxFrame <= ChunkHeaderLen {
+		panic("ur: frame size too small")
+	}
+	payload := maxFrame - ChunkHeaderLen
+	n := max((len(data)+payload-1)/payload, 1)
+	if n > maxChunks {
+		panic("ur: too many frames")
+	}
+	sum := crc32.ChecksumIEEE(data)
+	frames := make([][]byte, n)
+	for i := range frames {
+		chunk := data[min(i*payload, len(data)):min((i+1)*payload, len(data))]
+		f := make([]byte, 0, ChunkHeaderLen+len(chunk))
+		f = append(f, ChunkVersion)
+		f = binary.BigEndian.AppendUint16(f, uint16(i))
+		f = binary.BigEndian.AppendUint16(f, uint16(n))
+		f = binary.BigEndian.AppendUint32(f, sum)
+		frames[i] = append(f, chunk...)
+	}
+	return frames
+}
+
+// ErrIncomplete is returned by Reassemble when frames are missing.
+var ErrIncomplete = errors.New("ur: incomplete frames")
+
+// Reassemble joins the frames returned by ChunkBytes. The frames may
+// be in any order, and repeated frames are ignored.
+func Reassemble(frames [][]byte) ([]byte, error) {
+	var parts [][]byte
+	var total int
+	var sum uint32
+	for i, f := range frames {
+		if len(f) < ChunkHeaderLen {
+			return nil, fmt.Errorf("ur: frame %d: too short", i)
+		}
+		if f[0] != ChunkVersion {
+			return nil, fmt.Errorf("ur: frame %d: unsupported version %d", i, f[0])
+		}
+		idx := int(binary.BigEndian.Uint16(f[1:]))
+		n := int(binary.BigEndian.Uint16(f[3:]))
+		s := binary.BigEndian.Uint32(f[5:])
+		if parts == nil {
+			if n == 0 {
+				return nil, fmt.Errorf("ur: frame %d: zero frame count", i)
+			}
+			parts, total, sum = make([][]byte, n), n, s
+		}
+		if n != total || s != sum {
+			return nil, fmt.Errorf("ur: frame %d: belongs to a different message", i)
+		}
+		if idx >= total {
+			return nil, fmt.Errorf("ur: frame %d: index %d out of range", i, idx)
+		}
+		chunk := f[ChunkHeaderLen:]
+		if p := parts[idx]; p != nil && !bytes.Equal(p, chunk) {
+			return nil, fmt.Errorf("ur: frame %d: conflicting frame %d", i, idx)
+		}
+		parts[idx] = chunk
+	}
+	if parts == nil {
+		return nil, fmt.Errorf("%w: no frames", ErrIncomplete)
+	}
+	var data []byte
+	for i, p := range parts {
+		if p == nil {
+			return nil, fmt.Errorf("%w: missing frame %d of %d", ErrIncomplete, i, total)
+		}
+		data = append(data, p...)
+	}
+	if crc32.ChecksumIEEE(data) != sum {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
@@ -0,0 +1,181 @@
+package ur
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// This file implements the fountain codes of BCR-2020-005 used to
+// split messages into an unbounded sequence of parts, any sufficient
+// subset of which reconstructs the message.
+
+// minFragmentLen is the smallest fragment length used when
+// splitting messages.
+const minFragmentLen = 10
+
+// fragmentLen returns the length of the fragments for a message of
+// length n, such that fragments are no longer than maxLen and
+// equally sized.
+func fragmentLen(n, maxLen int) int {
+	maxCount := max(n/minFragmentLen, 1)
+	l := n
+	for count := 1; count <= maxCount; count++ {
+		l = (n + count - 1) / count
+		if l <= maxLen {
+			break
+		}
+	}
+	return l
+}
+
+// partition splits message into fragments of length l, padding
+// the last fragment with zeros.
+func partition(message []byte, l int) [][]byte {
+	var frags [][]byte
+	for len(message) > 0 {
+		f := make([]byte, l)
+		n := copy(f, message)
+		message = message[n:]
+		frags = append(frags, f)
+	}
+	return frags
+}
+
+// chooseFragments returns the indices of the fragments mixed into
+// the part with sequence number seqNum.
+func chooseFragments(seqNum, seqLen, checksum uint32) []int {
+	if seqNum <= seqLen {
+		return []int{int(seqNum - 1)}
+	}
+	var seed [8]byte
+	binary.BigEndian.PutUint32(seed[:4], seqNum)
+	binary.BigEndian.PutUint32(seed[4:], checksum)
+	rng := newXoshiro(sha256.Sum256(seed[:]))
+	degree := chooseDegree(int(seqLen), rng)
+	remaining := make([]int, seqLen)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	// Shuffle and keep the first degree indices.
+	var indices []int
+	for len(remaining) > 0 && len(indices) < degree {
+		i := rng.nextInt(0, len(remaining)-1)
+		indices = append(indices, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return indices
+}
+
+// chooseDegree picks the number of fragments mixed into a part,
+// weighting degree d with 1/d.
+func chooseDegree(seqLen int, rng *xoshiro) int {
+	weights := make([]float64, seqLen)
+	for i := range weights {
+		weights[i] = 1 / float64(i+1)
+	}
+	return newSampler(weights).next(rng) + 1
+}
+
+// xoshiro is the xoshiro256** generator.
+type xoshiro struct {
+	s [4]uint64
+}
+
+func newXoshiro(seed [32]byte) *xoshiro {
+	x := new(xoshiro)
+	for i := range x.s {
+		x.s[i] = binary.BigEndian.Uint64(seed[i*8:])
+	}
+	return x
+}
+
+func (x *xoshiro) next() uint64 {
+	s := &x.s
+	result := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return result
+}
+
+func (x *xoshiro) nextDouble() float64 {
+	return float64(x.next()) / (float64(math.MaxUint64) + 1)
+}
+
+// nextInt returns an integer in the closed interval [low, high].
+func (x *xoshiro) nextInt(low, high int) int {
+	return int(x.nextDouble()*float64(high-low+1)) + low
+}
+
+// sampler is a Vose alias method sampler.
+type sampler struct {
+	probs   []float64
+	aliases []int
+}
+
+func newSampler(weights []float64) *sampler {
+	n := len(weights)
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+	p := make([]float64, n)
+	for i, w := range weights {
+		p[i] = w * float64(n) / sum
+	}
+	var small, large []int
+	for i := n - 1; i >= 0; i-- {
+		if p[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	s := &sampler{
+		probs:   make([]float64, n),
+		aliases: make([]int, n),
+	}
+	for len(small) > 0 && len(large) > 0 {
+		a := small[len(small)-1]
+		small = small[:len(small)-1]
+		g := large[len(large)-1]
+		large = large[:len(large)-1]
+		s.probs[a] = p[a]
+		s.aliases[a] = g
+		p[g] += p[a] - 1
+		if p[g] < 1 {
+			small = append(small, g)
+		} else {
+			large = append(large, g)
+		}
+	}
+	for _, g := range large {
+		s.probs[g] = 1
+	}
+	for _, a := range small {
+		s.probs[a] = 1
+	}
+	return s
+}
+
+func (s *sampler) next(rng *xoshiro) int {
+	r1 := rng.nextDouble()
+	r2 := rng.nextDouble()
+	i := int(float64(len(s.probs)) * r1)
+	if r2 < s.probs[i] {
+		return i
+	}
+	return s.aliases[i]
+}
+
+func xorInto(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
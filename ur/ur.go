@@ -0,0 +1,271 @@
+// Package ur implements Uniform Resources (BCR-2020-005), the encoding
+// used to transfer CBOR data such as crypto-output descriptors over
+// (animated) QR codes.
+package ur
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+
+	"github.com/seedhammer/bip-serialized-descriptors/internal/cbor"
+)
+
+// Encode returns the single part UR of message with type typ, such as
+// "crypto-output".
+func Encode(typ string, message []byte) string {
+	return "ur:" + typ + "/" + encodeBytewords(message)
+}
+
+// Encoder splits a message into a sequence of UR parts.
+type Encoder struct {
+	typ       string
+	message   []byte
+	checksum  uint32
+	fragments [][]byte
+	seqNum    uint32
+}
+
+// NewEncoder returns an encoder for message with type typ, whose parts
+// carry at most maxFragmentLen bytes of the message each.
+func NewEncoder(typ string, message []byte, maxFragmentLen int) *Encoder {
+	l := fragmentLen(len(message), max(maxFragmentLen, minFragmentLen))
+	return &Encoder{
+		typ:       typ,
+		message:   message,
+		checksum:  crc32.ChecksumIEEE(message),
+		fragments: partition(message, l),
+	}
+}
+
+// SeqLen returns the number of fragments. Any SeqLen parts are
+// usually, but not always, enough to reconstruct the message.
+func (e *Encoder) SeqLen() int {
+	return len(e.fragments)
+}
+
+// NextPart returns the next part. The first SeqLen parts each contain
+// a single fragment, while the following parts mix several fragments.
+// A message that fits in a single fragment is encoded as a single
+// part UR.
+func (e *Encoder) NextPart() string {
+	if len(e.fragments) <= 1 {
+		return Encode(e.typ, e.message)
+	}
+	e.seqNum++
+	seqLen := uint32(len(e.fragments))
+	frag := make([]byte, len(e.fragments[0]))
+	for _, idx := range chooseFragments(e.seqNum, seqLen, e.checksum) {
+		xorInto(frag, e.fragments[idx])
+	}
+	enc := new(cbor.Encoder)
+	enc.Array(5)
+	enc.Uint(uint64(e.seqNum))
+	enc.Uint(uint64(seqLen))
+	enc.Uint(uint64(len(e.message)))
+	enc.Uint(uint64(e.checksum))
+	enc.ByteString(frag)
+	return fmt.Sprintf("ur:%s/%d-%d/%s", e.typ, e.seqNum, seqLen, encodeBytewords(enc.Bytes()))
+}
+
+// Limits of multipart messages accepted by Decoder. They bound the
+// memory and work spent on parts before the message is verified.
+const (
+	// MaxMessageLen is the maximum length of a multipart message.
+	MaxMessageLen = 1 << 24
+	// MaxSeqLen is the maximum number of fragments of a
+	// multipart message.
+	MaxSeqLen = 1 << 16
+)
+
+// Decoder reassembles a message from UR parts.
+type Decoder struct {
+	typ        string
+	seqLen     int
+	messageLen int
+	checksum   uint32
+	fragLen    int
+	simple     map[int][]byte
+	mixed      []mixedPart
+	message    []byte
+}
+
+// mixedPart is a part whose data is the exclusive or of
+// several fragments.
+type mixedPart struct {
+	indices []int
+	data    []byte
+}
+
+// Add adds a part to the decoder. Parts that don't match the type
+// or parameters of the previous parts are rejected.
+func (d *Decoder) Add(part string) error {
+	if d.message != nil {
+		return nil
+	}
+	part = strings.ToLower(part)
+	rest, ok := strings.CutPrefix(part, "ur:")
+	if !ok {
+		return errors.New("ur: missing ur: prefix")
+	}
+	elems := strings.Split(rest, "/")
+	typ := elems[0]
+	if typ == "" || strings.Trim(typ, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+		return fmt.Errorf("ur: invalid type %q", typ)
+	}
+	if d.typ != "" && typ != d.typ {
+		return fmt.Errorf("ur: part type %q doesn't match %q", typ, d.typ)
+	}
+	switch len(elems) {
+	case 2:
+		if d.typ != "" {
+			return errors.New("ur: single part UR in multipart sequence")
+		}
+		msg, err := decodeBytewords(elems[1])
+		if err != nil {
+			return err
+		}
+		d.typ, d.message = typ, msg
+		return nil
+	case 3:
+		return d.addPart(typ, elems[1], elems[2])
+	default:
+		return fmt.Errorf("ur: invalid path %q", rest)
+	}
+}
+
+func (d *Decoder) addPart(typ, seq, payload string) error {
+	seqNumStr, seqLenStr, ok := strings.Cut(seq, "-")
+	if !ok {
+		return fmt.Errorf("ur: invalid sequence %q", seq)
+	}
+	seqNum, err1 := strconv.ParseUint(seqNumStr, 10, 32)
+	seqLen, err2 := strconv.ParseUint(seqLenStr, 10, 32)
+	if err1 != nil || err2 != nil || seqNum == 0 || seqLen == 0 {
+		return fmt.Errorf("ur: invalid sequence %q", seq)
+	}
+	if seqLen > MaxSeqLen {
+		return fmt.Errorf("ur: sequence length %d exceeds %d", seqLen, MaxSeqLen)
+	}
+	data, err := decodeBytewords(payload)
+	if err != nil {
+		return err
+	}
+	dec := cbor.NewDecoder(data)
+	n, err := dec.Array()
+	if err != nil {
+		return fmt.Errorf("ur: invalid part: %w", err)
+	}
+	if n != 5 {
+		return fmt.Errorf("ur: invalid part: %d elements", n)
+	}
+	var hdr [4]uint64
+	for i := range hdr {
+		hdr[i], err = dec.Uint()
+		if err != nil {
+			return fmt.Errorf("ur: invalid part: %w", err)
+		}
+	}
+	frag, err := dec.ByteString()
+	if err != nil {
+		return fmt.Errorf("ur: invalid part: %w", err)
+	}
+	if dec.Len() > 0 {
+		return errors.New("ur: invalid part: trailing data")
+	}
+	if hdr[0] != seqNum || hdr[1] != seqLen {
+		return fmt.Errorf("ur: part sequence %d-%d doesn't match %q", hdr[0], hdr[1], seq)
+	}
+	messageLen, checksum := hdr[2], hdr[3]
+	if messageLen > MaxMessageLen {
+		return fmt.Errorf("ur: message length %d exceeds %d", messageLen, MaxMessageLen)
+	}
+	// The fragments must cover the message with less than a
+	// fragment of padding. Both factors are bounded, so the
+	// products don't overflow.
+	if checksum > 0xffffffff || len(frag) == 0 ||
+		uint64(len(frag))*seqLen < messageLen || uint64(len(frag))*(seqLen-1) >= messageLen {
+		return errors.New("ur: inconsistent part parameters")
+	}
+	if d.typ == "" {
+		d.typ = typ
+		d.seqLen = int(seqLen)
+		d.messageLen = int(messageLen)
+		d.checksum = uint32(checksum)
+		d.fragLen = len(frag)
+		d.simple = make(map[int][]byte)
+	} else if int(seqLen) != d.seqLen || int(messageLen) != d.messageLen ||
+		uint32(checksum) != d.checksum || len(frag) != d.fragLen {
+		return errors.New("ur: part doesn't match previous parts")
+	}
+	indices := chooseFragments(uint32(seqNum), uint32(seqLen), d.checksum)
+	d.mixed = append(d.mixed, mixedPart{indices: indices, data: frag})
+	d.reduce()
+	if len(d.simple) == d.seqLen {
+		return d.join()
+	}
+	return nil
+}
+
+// reduce removes known fragments from mixed parts until no more
+// fragments can be recovered.
+func (d *Decoder) reduce() {
+	for progress := true; progress; {
+		progress = false
+		remaining := d.mixed[:0]
+		for _, p := range d.mixed {
+			var indices []int
+			for _, idx := range p.indices {
+				if f, ok := d.simple[idx]; ok {
+					xorInto(p.data, f)
+				} else {
+					indices = append(indices, idx)
+				}
+			}
+			switch len(indices) {
+			case 0:
+				// Nothing new.
+			case 1:
+				d.simple[indices[0]] = p.data
+				progress = true
+			default:
+				remaining = append(remaining, mixedPart{indices: indices, data: p.data})
+			}
+		}
+		d.mixed = remaining
+	}
+}
+
+// join assembles the message from the complete set of fragments.
+func (d *Decoder) join() error {
+	msg := make([]byte, 0, d.seqLen*d.fragLen)
+	for i := 0; i < d.seqLen; i++ {
+		msg = append(msg, d.simple[i]...)
+	}
+	msg = msg[:d.messageLen]
+	if crc32.ChecksumIEEE(msg) != d.checksum {
+		return ErrChecksum
+	}
+	d.message = msg
+	d.simple, d.mixed = nil, nil
+	return nil
+}
+
+// Progress returns the fraction of fragments recovered.
+func (d *Decoder) Progress() float64 {
+	if d.message != nil {
+		return 1
+	}
+	if d.seqLen == 0 {
+		return 0
+	}
+	return float64(len(d.simple)) / float64(d.seqLen)
+}
+
+// Result returns the type and message once all parts
+// have been received.
+func (d *Decoder) Result() (typ string, message []byte, ok bool) {
+	return d.typ, d.message, d.message != nil
+}
@@ -0,0 +1,135 @@
+package ur
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/seedhammer/bip-serialized-descriptors/internal/cbor"
+)
+
+// The vectors are from the reference implementations of BCR-2020-005
+// and BCR-2020-012.
+
+func TestBytewords(t *testing.T) {
+	data := []byte{0, 1, 2, 128, 255}
+	const want = "aeadaolazmjendeoti"
+	if got := encodeBytewords(data); got != want {
+		t.Errorf("encodeBytewords(%x) = %s, want %s", data, got, want)
+	}
+	got, err := decodeBytewords("AEADAOLAZMJENDEOTI")
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("decodeBytewords(%s) = %x, %v, want %x", want, got, err, data)
+	}
+	if _, err := decodeBytewords("aeadaolazmjendeota"); err == nil {
+		t.Error("decodeBytewords accepted an invalid checksum")
+	}
+}
+
+func TestXoshiro(t *testing.T) {
+	want := []uint64{
+		42, 81, 85, 8, 82, 84, 76, 73, 70, 88, 2, 74, 40, 48, 77, 54, 88, 7, 5, 88,
+		37, 25, 82, 13, 69, 59, 30, 39, 11, 82, 19, 99, 45, 87, 30, 15, 32, 22, 89, 44,
+		92, 77, 29, 78, 4, 92, 44, 68, 92, 69, 1, 42, 89, 50, 37, 84, 63, 34, 32, 3,
+		17, 62, 40, 98, 82, 89, 24, 43, 85, 39, 15, 3, 99, 29, 20, 42, 27, 10, 85, 66,
+		50, 35, 69, 70, 70, 74, 30, 13, 72, 54, 11, 5, 70, 55, 91, 52, 10, 43, 43, 52,
+	}
+	rng := newXoshiro(sha256.Sum256([]byte("Wolf")))
+	for i, w := range want {
+		if got := rng.next() % 100; got != w {
+			t.Fatalf("output %d: %d, want %d", i, got, w)
+		}
+	}
+}
+
+// testMessage returns the CBOR byte string of the pseudo-random
+// message of the reference tests.
+func testMessage(n int) []byte {
+	rng := newXoshiro(sha256.Sum256([]byte("Wolf")))
+	msg := make([]byte, n)
+	for i := range msg {
+		msg[i] = byte(rng.nextInt(0, 255))
+	}
+	enc := new(cbor.Encoder)
+	enc.ByteString(msg)
+	return enc.Bytes()
+}
+
+func TestSinglePart(t *testing.T) {
+	const want = "ur:bytes/hdeymejtswhhylkepmykhhtsytsnoyoyaxaedsuttydmmhhpktpmsrjtgwdpfnsboxgwlbaawzuefywkdplrsrjynbvygabwjldapfcsdwkbrkch"
+	msg := testMessage(50)
+	if got := Encode("bytes", msg); got != want {
+		t.Errorf("Encode = %s, want %s", got, want)
+	}
+	d := new(Decoder)
+	if err := d.Add(want); err != nil {
+		t.Fatal(err)
+	}
+	typ, got, ok := d.Result()
+	if !ok || typ != "bytes" || !bytes.Equal(got, msg) {
+		t.Errorf("Result() = %s, %x, %v", typ, got, ok)
+	}
+}
+
+func TestMultiPartVector(t *testing.T) {
+	const want = "ur:bytes/1-9/lpadascfadaxcywenbpljkhdcahkadaemejtswhhylkepmykhhtsytsnoyoyaxaedsuttydmmhhpktpmsrjtdkgslpgh"
+	e := NewEncoder("bytes", testMessage(256), 30)
+	if got := e.NextPart(); got != want {
+		t.Errorf("first part %s, want %s", got, want)
+	}
+}
+
+func TestMultiPart(t *testing.T) {
+	msg := testMessage(32767)
+	e := NewEncoder("bytes", msg, 1000)
+	d := new(Decoder)
+	for i := 0; i < 10*e.SeqLen(); i++ {
+		part := e.NextPart()
+		// Drop every third part.
+		if i%3 == 2 {
+			continue
+		}
+		if err := d.Add(part); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, ok := d.Result(); ok {
+			break
+		}
+	}
+	typ, got, ok := d.Result()
+	if !ok {
+		t.Fatalf("message incomplete, progress %.2f", d.Progress())
+	}
+	if typ != "bytes" || !bytes.Equal(got, msg) {
+		t.Errorf("Result() = %s, %d bytes, want %d bytes", typ, len(got), len(msg))
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	part := func(seqNum, seqLen, messageLen uint64, fragLen int) string {
+		enc := new(cbor.Encoder)
+		enc.Array(5)
+		enc.Uint(seqNum)
+		enc.Uint(seqLen)
+		enc.Uint(messageLen)
+		enc.Uint(0)
+		enc.ByteString(make([]byte, fragLen))
+		return fmt.Sprintf("ur:bytes/%d-%d/%s", seqNum, seqLen, encodeBytewords(enc.Bytes()))
+	}
+	tests := []struct {
+		name string
+		part string
+	}{
+		{"huge sequence", part(4294967295, 2147483648, 2147483648, 1)},
+		{"long sequence", part(MaxSeqLen+2, MaxSeqLen+1, MaxSeqLen+1, 1)},
+		{"long message", part(2, 2, MaxMessageLen+1, MaxMessageLen/2+1)},
+		{"short fragments", part(1, 4, 100, 10)},
+		{"long fragments", part(1, 4, 10, 10)},
+	}
+	for _, test := range tests {
+		if err := new(Decoder).Add(test.part); err == nil {
+			t.Errorf("%s: Add succeeded", test.name)
+		}
+	}
+}